@@ -1,25 +1,81 @@
 package main
 
 import (
+	"bufio"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"git.lan/wikithing/web"
+	"git.lan/wikithing/wtbolt"
+	"git.lan/wikithing/wtfs"
+	"git.lan/wikithing/wtgit"
 )
 
 func main() {
-	var templd, staticd, datad, url string
+	var templd, staticd, datad, dbf, gitd, mediau, url string
+	var norepair bool
+	var locks, admin string
+	lockOpts := wtfs.DefaultLockOptions
 	flag.StringVar(&templd, "templ", "", "override the page generation templates")
 	flag.StringVar(&staticd, "static", "", "override the static resources dir")
 	flag.StringVar(&datad, "data", "./data/", "override the data directory")
+	flag.StringVar(&dbf, "db", "", "keep the wiki in a single bbolt database file instead of the data directory")
+	flag.StringVar(&gitd, "git", "", "keep pages in a git repository in this directory, everything else stays in the data directory")
+	flag.BoolVar(&norepair, "norepair", false, "only report anything left half saved in the data directory instead of fixing it")
+	flag.DurationVar(&lockOpts.Timeout, "locktimeout", lockOpts.Timeout, "how long to wait for something else to finish changing a file")
+	flag.DurationVar(&lockOpts.Stale, "lockstale", lockOpts.Stale, "how old locks get before they're assumed to be left over and broken, 0 to only break ones from processes that have stopped")
+	flag.StringVar(&locks, "locks", "", "list the locks held in the data directory, or clear them with clear (or clearstale for only the stale ones), then exit")
+	flag.StringVar(&admin, "admin", "", "make this user an admin then exit, if they don't exist yet they're created with a password read from stdin")
+	flag.StringVar(&mediau, "media", "http://localhost:5555/", "the url of the media server")
 	flag.StringVar(&url, "url", ":7380", "the url and port to run off of")
 	flag.Parse()
 
+	var store wtfs.Store
+	if dbf != "" {
+		db, err := wtbolt.Open(dbf)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer db.Close()
+		store = db
+	} else {
+		data, err := wtfs.New(datad)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		data.Locking = lockOpts
+		if locks != "" {
+			listLocks(data, locks)
+			return
+		}
+		check(data, !norepair)
+		store = data
+
+		if gitd != "" {
+			store, err = wtgit.Open(gitd, data)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	}
+
+	if admin != "" {
+		makeAdmin(store, admin)
+		return
+	}
+
 	s := web.Site{}
 	err := s.Initialise(web.Options{
 		TemplateDir: templd,
 		StaticDir:   staticd,
 		DataDir:     datad,
+		Store:       store,
+		MediaURL:    mediau,
 
 		RevealRawErr: true,
 	})
@@ -30,3 +86,65 @@ func main() {
 	err = s.Run(url)
 	log.Println(err)
 }
+
+// check looks for anything left half saved in the data directory from the last time the wiki stopped
+func check(f *wtfs.Filesystem, repair bool) {
+	l, err := f.Check(repair)
+	for _, x := range l {
+		log.Println("Check:", x)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// makeAdmin makes a user an admin, asking for a password if they need creating
+func makeAdmin(store wtfs.Store, name string) {
+	var password string
+	if _, err := store.LoadUser(strings.ToLower(name)); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Password for %v: ", name)
+		password, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			log.Fatalln(err)
+		}
+		password = strings.TrimRight(password, "\r\n")
+	}
+
+	created, err := web.MakeAdmin(store, name, password)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if created {
+		fmt.Println("Created the admin", name)
+	} else {
+		fmt.Println(name, "is an admin")
+	}
+}
+
+// listLocks lists the locks in the data directory, clearing them too if asked to
+func listLocks(f *wtfs.Filesystem, do string) {
+	if do != "list" && do != "clear" && do != "clearstale" {
+		log.Fatalln("-locks is either list, clear or clearstale")
+	}
+
+	l, err := f.Locks()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, x := range l {
+		state := ""
+		if x.Stale {
+			state = " (stale)"
+		}
+		if do == "clear" || (do == "clearstale" && x.Stale) {
+			if err := f.ClearLock(x.File); err != nil && !os.IsNotExist(err) {
+				log.Fatalln(err)
+			}
+			state += " cleared"
+		}
+		fmt.Printf("%v\tprocess %v on %v since %v%v\n", x.File, x.PID, x.Host, x.Time.Format(time.RFC3339), state)
+	}
+	if len(l) == 0 {
+		fmt.Println("Nothing is locked")
+	}
+}
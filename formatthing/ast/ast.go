@@ -0,0 +1,208 @@
+// Package ast contains the syntax tree formatthing documents are parsed into
+package ast
+
+import (
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+)
+
+// Node is any part of a document
+type Node interface {
+	node()
+}
+
+// Block is a node that takes up its own lines, like a paragraph or heading
+type Block interface {
+	Node
+	block()
+}
+
+// Inline is a node that goes inside of a block, like text or a link
+type Inline interface {
+	Node
+	inline()
+}
+
+// Document is the root of a parsed formatthing body
+type Document struct {
+	Children []Block
+}
+
+// Heading is a heading of level 1 to 6
+type Heading struct {
+	Level   int
+	Content []Inline
+}
+
+// Paragraph is a group of lines of text
+type Paragraph struct {
+	Content []Inline
+}
+
+// List is an ordered or unordered list, nested lists go in the Children of an item
+type List struct {
+	Ordered bool
+	Items   []*ListItem
+}
+
+// ListItem is a single entry in a List
+type ListItem struct {
+	Content  []Inline
+	Children *List
+}
+
+// CodeBlock is a fenced block of preformatted text
+type CodeBlock struct {
+	Lang string
+	Text string
+}
+
+// Quote is a block quote, containing more blocks
+type Quote struct {
+	Children []Block
+}
+
+// Rule is a horizontal rule
+type Rule struct{}
+
+// Infobox shows the table of the page it is on
+type Infobox struct {
+	Title string
+}
+
+// Query shows the results of a query over the tables of pages
+type Query struct {
+	Query string
+}
+
+// Text is plain text
+type Text struct {
+	Value string
+}
+
+// Strong is bold text
+type Strong struct {
+	Content []Inline
+}
+
+// Emphasis is italic text
+type Emphasis struct {
+	Content []Inline
+}
+
+// Strike is struck through text
+type Strike struct {
+	Content []Inline
+}
+
+// Code is inline code
+type Code struct {
+	Value string
+}
+
+// LineBreak is a forced line break
+type LineBreak struct{}
+
+// WikiLink is a link to another page on the wiki
+type WikiLink struct {
+	Target wikithing.Path
+	Anchor string
+	Label  string
+}
+
+// ExternalLink is a link going off the wiki
+type ExternalLink struct {
+	URL   string
+	Label string
+}
+
+// Media is an embedded media file, referred to by either its ID or its hash
+type Media struct {
+	ID      sid.ID
+	Hash    string
+	Caption string
+}
+
+func (*Document) node()  {}
+func (*Heading) node()   {}
+func (*Paragraph) node() {}
+func (*List) node()      {}
+func (*ListItem) node()  {}
+func (*CodeBlock) node() {}
+func (*Quote) node()     {}
+func (*Rule) node()      {}
+func (*Infobox) node()   {}
+func (*Query) node()     {}
+
+func (*Heading) block()   {}
+func (*Paragraph) block() {}
+func (*List) block()      {}
+func (*CodeBlock) block() {}
+func (*Quote) block()     {}
+func (*Rule) block()      {}
+func (*Infobox) block()   {}
+func (*Query) block()     {}
+
+func (*Text) node()         {}
+func (*Strong) node()       {}
+func (*Emphasis) node()     {}
+func (*Strike) node()       {}
+func (*Code) node()         {}
+func (*LineBreak) node()    {}
+func (*WikiLink) node()     {}
+func (*ExternalLink) node() {}
+func (*Media) node()        {}
+
+func (*Text) inline()         {}
+func (*Strong) inline()       {}
+func (*Emphasis) inline()     {}
+func (*Strike) inline()       {}
+func (*Code) inline()         {}
+func (*LineBreak) inline()    {}
+func (*WikiLink) inline()     {}
+func (*ExternalLink) inline() {}
+func (*Media) inline()        {}
+
+// Walk calls fn on n and everything under it, depth first,
+// if fn returns false the children of that node are skipped
+func Walk(n Node, fn func(Node) bool) {
+	if !fn(n) {
+		return
+	}
+
+	switch x := n.(type) {
+	case *Document:
+		for _, c := range x.Children {
+			Walk(c, fn)
+		}
+	case *Quote:
+		for _, c := range x.Children {
+			Walk(c, fn)
+		}
+	case *List:
+		for _, c := range x.Items {
+			Walk(c, fn)
+		}
+	case *ListItem:
+		walkInline(x.Content, fn)
+		if x.Children != nil {
+			Walk(x.Children, fn)
+		}
+	case *Heading:
+		walkInline(x.Content, fn)
+	case *Paragraph:
+		walkInline(x.Content, fn)
+	case *Strong:
+		walkInline(x.Content, fn)
+	case *Emphasis:
+		walkInline(x.Content, fn)
+	case *Strike:
+		walkInline(x.Content, fn)
+	}
+}
+
+func walkInline(l []Inline, fn func(Node) bool) {
+	for _, c := range l {
+		Walk(c, fn)
+	}
+}
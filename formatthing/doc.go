@@ -0,0 +1,55 @@
+/*
+Package formatthing implements formatthing, the wiki's own lightweight markup language.
+
+It exists so there is a place to put syntax that is specific to the wiki (links between pages, media, infoboxes)
+without having to bend markdown into shape, and it is meant to be simple enough to write by hand without looking things up.
+
+A document is split into blocks by lines,
+and the text inside of those blocks is then split into inline pieces.
+Parse turns a document into an ast.Document, and HTML turns that into html.
+Parsing never fails, anything that isn't understood is kept as plain text.
+
+Blocks
+
+	= Heading           a heading, the number of = is the level (1 to 6),
+	== Smaller heading  any = on the end of the line are ignored
+
+	- item              an unordered list item
+	-- nested item      more - nests it further
+	# item              an ordered list item, # nests the same way as -
+
+	> quoted            a block quote, the text after > is parsed as its own document
+	----                a horizontal rule, 4 or more - with nothing else on the line
+
+	```lang             a code block, everything up until the next ``` line is kept as is,
+	code                the lang is optional
+	```
+
+	{{infobox}}         shows the page's table as an infobox, on a line of its own
+	{{infobox|Title}}   the same with a different title to the one in the table
+	{{query|...}}       a table of the pages matching a query, see the wtquery package for what queries look like
+
+Any other run of lines not separated by a blank line is a paragraph.
+
+Inline
+
+	**strong**          bold text
+	//emphasis//        italic text, a // directly after a : (like in https://) is left alone
+	~~strike~~          struck through text
+	`code`              inline code, nothing inside of it is formatted
+
+	[[some.page]]             a link to another page on the wiki, the target is parsed the same way as any other path
+	[[some.page|label]]       the same with a label
+	[[some.page#section]]     linking to a heading on a page, headings get ids from their text (lowercased, spaces as -)
+	[[https://example.com]]   a link off the wiki, only http, https and mailto are allowed
+	[[https://example.com|x]] with a label
+
+	{{media:123456}}          embed a media file by its sid.ID (base 10, or base 16 prefixed by x)
+	{{media:#hash}}           embed a media file by its hash
+	{{media:123456|caption}}  either can have a caption, which is also used as alt text
+
+A backslash before any punctuation character shows that character as is,
+and a backslash at the end of a line forces a line break.
+Delimiters that are never closed are shown as plain text.
+*/
+package formatthing
@@ -0,0 +1,313 @@
+package formatthing
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/formatthing/ast"
+)
+
+// Options changes how a document gets turned into html
+type Options struct {
+	// Table is what gets shown by {{infobox}}
+	Table wikithing.Table
+
+	// MediaURL is where media files are served from, the hash of a file is added onto the end of it,
+	// if it's empty there's nowhere to get media from so it's shown as unavailable
+	MediaURL string
+	// Media looks up a media file by its ID, if it's nil or fails then media embedded by ID is shown as missing
+	Media func(id sid.ID) (wikithing.FileObject, error)
+	// Query runs a {{query}} and gives back the html to show for it, if it's nil then queries aren't run
+	Query func(q string) []byte
+}
+
+// Render parses and renders a document in one go
+func Render(src string, opts Options) []byte {
+	return HTML(Parse(src), opts)
+}
+
+// HTML renders a parsed document into html, everything from the document is escaped
+func HTML(doc *ast.Document, opts Options) []byte {
+	h := htmlRenderer{
+		opts: opts,
+		ids:  map[string]int{},
+	}
+	h.blocks(doc.Children)
+	return h.buf.Bytes()
+}
+
+var errNoMediaLookup = errors.New("no way to look up media by id")
+
+type htmlRenderer struct {
+	buf  bytes.Buffer
+	opts Options
+
+	// heading ids that have been used so far, so duplicates can be numbered
+	ids map[string]int
+}
+
+func (h *htmlRenderer) str(s ...string) {
+	for _, x := range s {
+		h.buf.WriteString(x)
+	}
+}
+
+func (h *htmlRenderer) esc(s string) {
+	template.HTMLEscape(&h.buf, []byte(s))
+}
+
+func (h *htmlRenderer) blocks(l []ast.Block) {
+	for _, x := range l {
+		h.block(x)
+	}
+}
+
+func (h *htmlRenderer) block(b ast.Block) {
+	switch x := b.(type) {
+	case *ast.Heading:
+		lv := strconv.Itoa(x.Level)
+		h.str("<h", lv, ` id="`)
+		h.esc(h.headingID(x.Content))
+		h.str(`">`)
+		h.inlines(x.Content)
+		h.str("</h", lv, ">\n")
+
+	case *ast.Paragraph:
+		h.str("<p>")
+		h.inlines(x.Content)
+		h.str("</p>\n")
+
+	case *ast.List:
+		h.list(x)
+
+	case *ast.CodeBlock:
+		h.str("<pre><code")
+		if x.Lang != "" {
+			h.str(` class="language-`)
+			h.esc(x.Lang)
+			h.str(`"`)
+		}
+		h.str(">")
+		h.esc(x.Text)
+		h.str("</code></pre>\n")
+
+	case *ast.Quote:
+		h.str("<blockquote>\n")
+		h.blocks(x.Children)
+		h.str("</blockquote>\n")
+
+	case *ast.Rule:
+		h.str("<hr>\n")
+
+	case *ast.Infobox:
+		h.infobox(x)
+
+	case *ast.Query:
+		if h.opts.Query == nil {
+			h.str(`<p class="query-error">queries can't be run here</p>`, "\n")
+			return
+		}
+		h.buf.Write(h.opts.Query(x.Query))
+	}
+}
+
+func (h *htmlRenderer) list(l *ast.List) {
+	tag := "ul"
+	if l.Ordered {
+		tag = "ol"
+	}
+
+	h.str("<", tag, ">\n")
+	for _, x := range l.Items {
+		h.str("<li>")
+		h.inlines(x.Content)
+		if x.Children != nil {
+			h.str("\n")
+			h.list(x.Children)
+		}
+		h.str("</li>\n")
+	}
+	h.str("</", tag, ">\n")
+}
+
+func (h *htmlRenderer) infobox(ib *ast.Infobox) {
+	t := h.opts.Table
+	if len(t.Fields) == 0 {
+		return
+	}
+
+	title := t.Title
+	if ib.Title != "" {
+		title = ib.Title
+	}
+
+	h.str(`<table class="infobox">`, "\n")
+	if title != "" {
+		h.str("<caption>")
+		h.esc(title)
+		h.str("</caption>\n")
+	}
+	for _, x := range t.Fields {
+		h.str("<tr><th>")
+		h.esc(x.Key)
+		h.str("</th><td>")
+		for i, v := range x.Rows {
+			if i > 0 {
+				h.str("<br>")
+			}
+			h.esc(v)
+		}
+		h.str("</td></tr>\n")
+	}
+	h.str("</table>\n")
+}
+
+func (h *htmlRenderer) inlines(l []ast.Inline) {
+	for _, x := range l {
+		h.inline(x)
+	}
+}
+
+func (h *htmlRenderer) inline(n ast.Inline) {
+	switch x := n.(type) {
+	case *ast.Text:
+		h.esc(x.Value)
+
+	case *ast.Strong:
+		h.str("<strong>")
+		h.inlines(x.Content)
+		h.str("</strong>")
+
+	case *ast.Emphasis:
+		h.str("<em>")
+		h.inlines(x.Content)
+		h.str("</em>")
+
+	case *ast.Strike:
+		h.str("<del>")
+		h.inlines(x.Content)
+		h.str("</del>")
+
+	case *ast.Code:
+		h.str("<code>")
+		h.esc(x.Value)
+		h.str("</code>")
+
+	case *ast.LineBreak:
+		h.str("<br>\n")
+
+	case *ast.WikiLink:
+		h.str(`<a class="wikilink" href="`)
+		h.esc(wikithing.Link{Target: x.Target, Anchor: x.Anchor}.URL())
+		h.str(`">`)
+		h.esc(x.Label)
+		h.str("</a>")
+
+	case *ast.ExternalLink:
+		h.str(`<a href="`)
+		h.esc(x.URL)
+		h.str(`" rel="nofollow">`)
+		h.esc(x.Label)
+		h.str("</a>")
+
+	case *ast.Media:
+		h.media(x)
+	}
+}
+
+func (h *htmlRenderer) media(m *ast.Media) {
+	if h.opts.MediaURL == "" {
+		h.str(`<span class="media-missing">media isn't available here</span>`)
+		return
+	}
+
+	// without a lookup there is no way of knowing what something embedded by hash is, so it's assumed to be an image
+	obj := wikithing.FileObject{
+		FileConfig: wikithing.FileConfig{Class: wikithing.FileClassImage, Hash: m.Hash},
+	}
+
+	if !m.ID.IsZero() {
+		var err error
+		if h.opts.Media == nil {
+			err = errNoMediaLookup
+		} else {
+			obj, err = h.opts.Media(m.ID)
+		}
+		if err != nil {
+			h.str(`<span class="media-missing">media `)
+			h.esc(m.ID.String())
+			h.str(" not found</span>")
+			return
+		}
+	}
+
+	src := h.opts.MediaURL + url.PathEscape(obj.Hash)
+
+	h.str(`<span class="media">`)
+	switch obj.Class {
+	case wikithing.FileClassImage:
+		h.str(`<img src="`)
+		h.esc(src)
+		h.str(`" alt="`)
+		h.esc(m.Caption)
+		h.str(`">`)
+	case wikithing.FileClassAudio:
+		h.str(`<audio controls src="`)
+		h.esc(src)
+		h.str(`"></audio>`)
+	case wikithing.FileClassVideo:
+		h.str(`<video controls src="`)
+		h.esc(src)
+		h.str(`"></video>`)
+	default:
+		h.str(`<a href="`)
+		h.esc(src)
+		h.str(`">`)
+		if m.Caption != "" {
+			h.esc(m.Caption)
+		} else {
+			h.esc(obj.Hash)
+		}
+		// the caption is already the link text
+		h.str("</a></span>")
+		return
+	}
+	if m.Caption != "" {
+		h.str(`<span class="caption">`)
+		h.esc(m.Caption)
+		h.str("</span>")
+	}
+	h.str("</span>")
+}
+
+// headingID makes an id for a heading out of its text so it can be linked to
+func (h *htmlRenderer) headingID(l []ast.Inline) string {
+	buf := strings.Builder{}
+	ast.Walk(&ast.Paragraph{Content: l}, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.Text:
+			buf.WriteString(x.Value)
+		case *ast.Code:
+			buf.WriteString(x.Value)
+		case *ast.WikiLink:
+			buf.WriteString(x.Label)
+		case *ast.ExternalLink:
+			buf.WriteString(x.Label)
+		}
+		return true
+	})
+
+	id := wikithing.Slug(buf.String())
+	n := h.ids[id]
+	h.ids[id]++
+	if n > 0 {
+		id += "-" + strconv.Itoa(n)
+	}
+	return id
+}
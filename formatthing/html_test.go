@@ -0,0 +1,112 @@
+package formatthing_test
+
+import (
+	"strconv"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/formatthing"
+	"git.lan/wikithing/wtfs"
+)
+
+func TestRender(t *testing.T) {
+	for _, x := range []struct {
+		name, src, want string
+	}{
+		// blocks
+		{"heading", "= Title =", `<h1 id="title">Title</h1>` + "\n"},
+		{"heading level", "=== Three", `<h3 id="three">Three</h3>` + "\n"},
+		{"duplicate headings", "= A\n= A", `<h1 id="a">A</h1>` + "\n" + `<h1 id="a-1">A</h1>` + "\n"},
+		{"paragraphs", "one\nstill one\n\ntwo", "<p>one\nstill one</p>\n<p>two</p>\n"},
+		{"list", "- a\n- b\n-- c", "<ul>\n<li>a</li>\n<li>b\n<ul>\n<li>c</li>\n</ul>\n</li>\n</ul>\n"},
+		{"ordered list", "# one\n# two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"quote", "> quoted **text**", "<blockquote>\n<p>quoted <strong>text</strong></p>\n</blockquote>\n"},
+		{"rule", "----", "<hr>\n"},
+		{"code block", "```go\nx := 1\n\n**y**\n```", `<pre><code class="language-go">x := 1` + "\n\n**y**</code></pre>\n"},
+		{"unclosed code block", "```\ncode", "<pre><code>code</code></pre>\n"},
+		{"infobox without a table", "{{infobox}}", ""},
+		{"query without a runner", "{{query|x}}", `<p class="query-error">queries can't be run here</p>` + "\n"},
+		{"unknown directive", "{{nothing}}", "<p>{{nothing}}</p>\n"},
+
+		// inline
+		{"formatting", "**b** //i// ~~s~~ `c **d**`", "<p><strong>b</strong> <em>i</em> <del>s</del> <code>c **d**</code></p>\n"},
+		{"nested", "**//both//**", "<p><strong><em>both</em></strong></p>\n"},
+		{"url isn't emphasis", "https://example.com //x//", "<p>https://example.com <em>x</em></p>\n"},
+		{"unclosed", "**open", "<p>**open</p>\n"},
+		{"backslash", `\*\*not\*\*`, "<p>**not**</p>\n"},
+		{"line break", "line\\\nnext", "<p>line<br>\nnext</p>\n"},
+		{"wiki link", "[[some.page]]", `<p><a class="wikilink" href="/page/some/page">some.page</a></p>` + "\n"},
+		{"wiki link label", "[[some.page|label]]", `<p><a class="wikilink" href="/page/some/page">label</a></p>` + "\n"},
+		{"wiki link anchor", "[[some.page#sec]]", `<p><a class="wikilink" href="/page/some/page#sec">some.page#sec</a></p>` + "\n"},
+		{"external link", "[[https://example.com|x]]", `<p><a href="https://example.com" rel="nofollow">x</a></p>` + "\n"},
+		{"bad scheme", "[[javascript:alert(1)|x]]", "<p>[[javascript:alert(1)|x]]</p>\n"},
+
+		// escaping
+		{"raw html", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"html in code", "`<b>`", "<p><code>&lt;b&gt;</code></p>\n"},
+		{"html in code block", "```\n</code><b>\n```", "<pre><code>&lt;/code&gt;&lt;b&gt;</code></pre>\n"},
+		{"code block lang", "```\"><b>\nx\n```", `<pre><code class="language-&#34;&gt;&lt;b&gt;">x</code></pre>` + "\n"},
+		{"link label", `[[some.page|"><b>]]`, `<p><a class="wikilink" href="/page/some/page">&#34;&gt;&lt;b&gt;</a></p>` + "\n"},
+		{"link url", `[[https://example.com/"><b>|x]]`, `<p><a href="https://example.com/%22%3E%3Cb%3E" rel="nofollow">x</a></p>` + "\n"},
+		{"link target", `[[some"page]]`, `<p><a class="wikilink" href="/page/somepage">some&#34;page</a></p>` + "\n"},
+		{"heading id", `= "x" <y>`, `<h1 id="x-y">&#34;x&#34; &lt;y&gt;</h1>` + "\n"},
+	} {
+		got := string(formatthing.Render(x.src, formatthing.Options{}))
+		if got != x.want {
+			t.Errorf("%v: %q came out as\n%q\nnot\n%q", x.name, x.src, got, x.want)
+		}
+	}
+}
+
+func TestRenderMedia(t *testing.T) {
+	store := wtfs.NewMemory()
+	cat := wikithing.FileObject{ID: sid.Get(), FileConfig: wikithing.FileConfig{Class: wikithing.FileClassImage, Hash: "abc"}}
+	song := wikithing.FileObject{ID: sid.Get(), FileConfig: wikithing.FileConfig{Class: wikithing.FileClassAudio, Hash: "def"}}
+	gone := wikithing.FileObject{ID: sid.Get(), FileConfig: wikithing.FileConfig{Class: wikithing.FileClassImage, Hash: "ghi"}}
+	for loc, o := range map[string]wikithing.FileObject{"cat": cat, "song": song, "gone": gone} {
+		err := store.SaveMedia(wikithing.ParsePath(loc), o, wikithing.LogEntry{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := store.DeleteMedia(wikithing.ParsePath("gone"), wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := func(id sid.ID) (wikithing.FileObject, error) {
+		_, o, err := store.MediaByID(id)
+		return o, err
+	}
+	missing := func(id sid.ID) string {
+		return `<p><span class="media-missing">media ` + id.String() + " not found</span></p>\n"
+	}
+
+	for _, x := range []struct {
+		name, src string
+		opts      formatthing.Options
+		want      string
+	}{
+		{"no media server", "{{media:#abc}}", formatthing.Options{Media: lookup},
+			`<p><span class="media-missing">media isn't available here</span></p>` + "\n"},
+		{"no media server by id", "{{media:" + cat.ID.Base10() + "}}", formatthing.Options{Media: lookup},
+			`<p><span class="media-missing">media isn't available here</span></p>` + "\n"},
+		{"hash", "{{media:#abc | a cat}}", formatthing.Options{MediaURL: "/m/"},
+			`<p><span class="media"><img src="/m/abc" alt="a cat"><span class="caption">a cat</span></span></p>` + "\n"},
+		{"hash escaped", `{{media:#a"b/c | "><b>}}`, formatthing.Options{MediaURL: "/m/"},
+			`<p><span class="media"><img src="/m/a%22b%2Fc" alt="&#34;&gt;&lt;b&gt;"><span class="caption">&#34;&gt;&lt;b&gt;</span></span></p>` + "\n"},
+		{"id", "{{media:" + cat.ID.Base10() + "|a cat}}", formatthing.Options{MediaURL: "/m/", Media: lookup},
+			`<p><span class="media"><img src="/m/abc" alt="a cat"><span class="caption">a cat</span></span></p>` + "\n"},
+		{"id in base 16", "{{media:x" + strconv.FormatUint(uint64(song.ID), 16) + "}}", formatthing.Options{MediaURL: "/m/", Media: lookup},
+			`<p><span class="media"><audio controls src="/m/def"></audio></span></p>` + "\n"},
+		{"id without a lookup", "{{media:" + cat.ID.Base10() + "}}", formatthing.Options{MediaURL: "/m/"}, missing(cat.ID)},
+		{"unknown id", "{{media:12345}}", formatthing.Options{MediaURL: "/m/", Media: lookup}, missing(12345)},
+		{"deleted", "{{media:" + gone.ID.Base10() + "}}", formatthing.Options{MediaURL: "/m/", Media: lookup}, missing(gone.ID)},
+	} {
+		got := string(formatthing.Render(x.src, x.opts))
+		if got != x.want {
+			t.Errorf("%v: %q came out as\n%q\nnot\n%q", x.name, x.src, got, x.want)
+		}
+	}
+}
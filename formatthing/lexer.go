@@ -0,0 +1,202 @@
+package formatthing
+
+import "strings"
+
+type lineKind uint
+
+const (
+	lineText lineKind = iota
+	lineBlank
+	lineHeading
+	lineList
+	lineQuote
+	lineRule
+	lineFence
+	lineRaw
+	lineDirective
+)
+
+// line is a single classified line of a document
+type line struct {
+	Kind lineKind
+
+	// heading level or list nesting
+	Depth   int
+	Ordered bool
+
+	// the line with any block markup removed
+	Text string
+}
+
+// lexLines splits a document up into lines and works out what kind of block each belongs to
+func lexLines(src string) []line {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	raw := strings.Split(src, "\n")
+	out := make([]line, 0, len(raw))
+
+	fenced := false
+	for _, x := range raw {
+		if fenced {
+			if strings.TrimSpace(x) == "```" {
+				fenced = false
+				out = append(out, line{Kind: lineFence})
+				continue
+			}
+			out = append(out, line{Kind: lineRaw, Text: x})
+			continue
+		}
+
+		l := lexLine(x)
+		if l.Kind == lineFence {
+			fenced = true
+		}
+		out = append(out, l)
+	}
+
+	return out
+}
+
+func lexLine(x string) line {
+	t := strings.TrimSpace(x)
+
+	switch {
+	case t == "":
+		return line{Kind: lineBlank}
+
+	case strings.HasPrefix(t, "```"):
+		return line{Kind: lineFence, Text: strings.TrimSpace(t[3:])}
+
+	case len(t) >= 4 && strings.Trim(t, "-") == "":
+		return line{Kind: lineRule}
+
+	case t[0] == '>':
+		return line{Kind: lineQuote, Text: strings.TrimPrefix(t[1:], " ")}
+
+	case strings.HasPrefix(t, "{{") && strings.HasSuffix(t, "}}") && !strings.Contains(t[2:len(t)-2], "}}"):
+		return line{Kind: lineDirective, Text: t[2 : len(t)-2]}
+	}
+
+	if n, rest, ok := leadingRun(t, '='); ok && n <= 6 {
+		return line{Kind: lineHeading, Depth: n, Text: strings.TrimSpace(strings.TrimRight(rest, "="))}
+	}
+	if n, rest, ok := leadingRun(t, '-'); ok {
+		return line{Kind: lineList, Depth: n, Text: rest}
+	}
+	if n, rest, ok := leadingRun(t, '#'); ok {
+		return line{Kind: lineList, Depth: n, Ordered: true, Text: rest}
+	}
+
+	return line{Kind: lineText, Text: t}
+}
+
+// leadingRun checks if s starts with one or more of c followed by a space
+func leadingRun(s string, c byte) (n int, rest string, ok bool) {
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	if n == 0 || n >= len(s) || s[n] != ' ' {
+		return 0, s, false
+	}
+	return n, strings.TrimSpace(s[n:]), true
+}
+
+type tokenKind uint
+
+const (
+	tokText tokenKind = iota
+	tokStrong
+	tokEmph
+	tokStrike
+	tokCode
+	tokLink
+	tokDirective
+	tokBreak
+)
+
+// token is a piece of inline text
+type token struct {
+	Kind tokenKind
+
+	// the text for text tokens, or the insides of code, links and directives
+	Value string
+}
+
+// delimiters that open and close spans of formatting
+var delims = map[string]tokenKind{
+	"**": tokStrong,
+	"//": tokEmph,
+	"~~": tokStrike,
+}
+
+// escapable is everything a backslash can escape
+const escapable = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// lexInline splits up the text of a block into tokens
+func lexInline(s string) []token {
+	var out []token
+	buf := strings.Builder{}
+
+	text := func(v string) { buf.WriteString(v) }
+	emit := func(t token) {
+		if buf.Len() > 0 {
+			out = append(out, token{Kind: tokText, Value: buf.String()})
+			buf.Reset()
+		}
+		out = append(out, t)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			emit(token{Kind: tokBreak})
+			i += 2
+			continue
+
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0:
+			text(s[i+1 : i+2])
+			i += 2
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				emit(token{Kind: tokCode, Value: s[i+1 : i+1+end]})
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(s[i:], "[["):
+			if end := strings.Index(s[i+2:], "]]"); end >= 0 {
+				emit(token{Kind: tokLink, Value: s[i+2 : i+2+end]})
+				i += end + 4
+				continue
+			}
+
+		case strings.HasPrefix(s[i:], "{{"):
+			if end := strings.Index(s[i+2:], "}}"); end >= 0 {
+				emit(token{Kind: tokDirective, Value: s[i+2 : i+2+end]})
+				i += end + 4
+				continue
+			}
+
+		case i+1 < len(s):
+			d, ok := delims[s[i:i+2]]
+			// leave the // in urls alone
+			if ok && !(d == tokEmph && i > 0 && s[i-1] == ':') {
+				emit(token{Kind: d, Value: s[i : i+2]})
+				i += 2
+				continue
+			}
+		}
+
+		text(s[i : i+1])
+		i++
+	}
+
+	if buf.Len() > 0 {
+		out = append(out, token{Kind: tokText, Value: buf.String()})
+	}
+
+	return out
+}
@@ -0,0 +1,314 @@
+package formatthing
+
+import (
+	"net/url"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/formatthing/ast"
+)
+
+// Parse parses a formatthing document, it never fails and anything it can't make sense of is kept as text
+func Parse(src string) *ast.Document {
+	return &ast.Document{
+		Children: parseBlocks(lexLines(src)),
+	}
+}
+
+func parseBlocks(ls []line) []ast.Block {
+	var out []ast.Block
+
+	for i := 0; i < len(ls); {
+		l := ls[i]
+
+		switch l.Kind {
+		case lineBlank:
+			i++
+
+		case lineHeading:
+			out = append(out, &ast.Heading{
+				Level:   l.Depth,
+				Content: parseInline(l.Text),
+			})
+			i++
+
+		case lineRule:
+			out = append(out, &ast.Rule{})
+			i++
+
+		case lineFence:
+			cb := &ast.CodeBlock{Lang: l.Text}
+			var text []string
+			for i++; i < len(ls) && ls[i].Kind == lineRaw; i++ {
+				text = append(text, ls[i].Text)
+			}
+			// skip the closing fence, if it's at the end of the document it may not be there
+			i++
+			cb.Text = strings.Join(text, "\n")
+			out = append(out, cb)
+
+		case lineQuote:
+			var text []string
+			for ; i < len(ls) && ls[i].Kind == lineQuote; i++ {
+				text = append(text, ls[i].Text)
+			}
+			out = append(out, &ast.Quote{
+				Children: parseBlocks(lexLines(strings.Join(text, "\n"))),
+			})
+
+		case lineList:
+			var list *ast.List
+			list, i = parseList(ls, i, l.Depth)
+			out = append(out, list)
+
+		case lineDirective:
+			if b, ok := parseBlockDirective(l.Text); ok {
+				out = append(out, b)
+				i++
+				continue
+			}
+			// anything else on its own is just part of a paragraph
+			fallthrough
+
+		default:
+			var text []string
+			for ; i < len(ls) && isParagraphLine(ls[i]); i++ {
+				if ls[i].Kind == lineDirective {
+					text = append(text, "{{"+ls[i].Text+"}}")
+					continue
+				}
+				text = append(text, ls[i].Text)
+			}
+			if len(text) == 0 {
+				// shouldn't happen, but never get stuck on a line
+				i++
+				continue
+			}
+			out = append(out, &ast.Paragraph{
+				Content: parseInline(strings.Join(text, "\n")),
+			})
+		}
+	}
+
+	return out
+}
+
+func isParagraphLine(l line) bool {
+	switch l.Kind {
+	case lineText:
+		return true
+	case lineDirective:
+		_, ok := parseBlockDirective(l.Text)
+		return !ok
+	}
+	return false
+}
+
+// parseList parses a list starting at ls[i], any deeper items are nested under the item before them
+func parseList(ls []line, i int, depth int) (*ast.List, int) {
+	list := &ast.List{Ordered: ls[i].Ordered}
+
+	for i < len(ls) && ls[i].Kind == lineList && ls[i].Depth >= depth {
+		l := ls[i]
+		// switching between - and # starts a new list
+		if l.Depth == depth && l.Ordered != list.Ordered {
+			break
+		}
+
+		if l.Depth > depth {
+			if len(list.Items) == 0 {
+				list.Items = append(list.Items, &ast.ListItem{})
+			}
+			last := list.Items[len(list.Items)-1]
+
+			var sub *ast.List
+			sub, i = parseList(ls, i, depth+1)
+			if last.Children == nil {
+				last.Children = sub
+			} else {
+				last.Children.Items = append(last.Children.Items, sub.Items...)
+			}
+			continue
+		}
+
+		list.Items = append(list.Items, &ast.ListItem{
+			Content: parseInline(l.Text),
+		})
+		i++
+	}
+
+	return list, i
+}
+
+// parseBlockDirective parses the directives that go on a line of their own
+func parseBlockDirective(d string) (ast.Block, bool) {
+	name, arg := splitDirective(d)
+	switch name {
+	case "infobox":
+		return &ast.Infobox{Title: arg}, true
+	case "query":
+		if arg == "" {
+			return nil, false
+		}
+		return &ast.Query{Query: arg}, true
+	}
+	return nil, false
+}
+
+// splitDirective splits the inside of a {{name|arg}} or {{name:arg}}
+func splitDirective(d string) (name, arg string) {
+	i := strings.IndexAny(d, ":|")
+	if i < 0 {
+		return strings.TrimSpace(strings.ToLower(d)), ""
+	}
+	return strings.TrimSpace(strings.ToLower(d[:i])), strings.TrimSpace(d[i+1:])
+}
+
+func parseInline(s string) []ast.Inline {
+	p := inlineParser{toks: lexInline(s)}
+	return p.parse(tokText)
+}
+
+type inlineParser struct {
+	toks []token
+	pos  int
+}
+
+// parse parses inline tokens until it hits closer (or runs out)
+func (p *inlineParser) parse(closer tokenKind) []ast.Inline {
+	var out []ast.Inline
+
+	for p.pos < len(p.toks) {
+		t := p.toks[p.pos]
+		p.pos++
+
+		switch t.Kind {
+		case tokStrong, tokEmph, tokStrike:
+			if t.Kind == closer {
+				return out
+			}
+			if !p.closed(t.Kind) {
+				out = appendText(out, t.Value)
+				continue
+			}
+
+			content := p.parse(t.Kind)
+			switch t.Kind {
+			case tokStrong:
+				out = append(out, &ast.Strong{Content: content})
+			case tokEmph:
+				out = append(out, &ast.Emphasis{Content: content})
+			case tokStrike:
+				out = append(out, &ast.Strike{Content: content})
+			}
+
+		case tokCode:
+			out = append(out, &ast.Code{Value: t.Value})
+
+		case tokBreak:
+			out = append(out, &ast.LineBreak{})
+
+		case tokLink:
+			if n := parseLink(t.Value); n != nil {
+				out = append(out, n)
+				continue
+			}
+			out = appendText(out, "[["+t.Value+"]]")
+
+		case tokDirective:
+			if n := parseMedia(t.Value); n != nil {
+				out = append(out, n)
+				continue
+			}
+			out = appendText(out, "{{"+t.Value+"}}")
+
+		default:
+			out = appendText(out, t.Value)
+		}
+	}
+
+	return out
+}
+
+// closed checks if there is a closing delimiter for kind anywhere ahead
+func (p *inlineParser) closed(kind tokenKind) bool {
+	for _, x := range p.toks[p.pos:] {
+		if x.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// appendText adds onto the last text node if there is one, instead of adding a new one
+func appendText(l []ast.Inline, s string) []ast.Inline {
+	if len(l) > 0 {
+		if t, ok := l[len(l)-1].(*ast.Text); ok {
+			t.Value += s
+			return l
+		}
+	}
+	return append(l, &ast.Text{Value: s})
+}
+
+// allowedSchemes are the kinds of external links that can be made
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+func parseLink(s string) ast.Inline {
+	target, label := s, ""
+	if i := strings.IndexByte(s, '|'); i >= 0 {
+		target, label = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+
+	if u, err := url.Parse(target); err == nil && u.Scheme != "" {
+		if !allowedSchemes[strings.ToLower(u.Scheme)] {
+			return nil
+		}
+		if label == "" {
+			label = target
+		}
+		return &ast.ExternalLink{URL: u.String(), Label: label}
+	}
+
+	l, ok := wikithing.ParseLink(s)
+	if !ok {
+		return nil
+	}
+	return &ast.WikiLink{
+		Target: l.Target,
+		Anchor: l.Anchor,
+		Label:  l.Label,
+	}
+}
+
+func parseMedia(d string) ast.Inline {
+	name, arg := splitDirective(d)
+	if name != "media" || arg == "" {
+		return nil
+	}
+
+	m := &ast.Media{}
+	if i := strings.IndexByte(arg, '|'); i >= 0 {
+		arg, m.Caption = strings.TrimSpace(arg[:i]), strings.TrimSpace(arg[i+1:])
+	}
+
+	if strings.HasPrefix(arg, "#") {
+		m.Hash = strings.TrimPrefix(arg, "#")
+		if m.Hash == "" {
+			return nil
+		}
+		return m
+	}
+
+	id, err := sid.Parse(arg)
+	if err != nil || id.IsZero() {
+		return nil
+	}
+	m.ID = id
+	return m
+}
@@ -5,8 +5,12 @@ go 1.16
 require (
 	github.com/disintegration/imaging v1.6.2
 	github.com/go-chi/chi v1.5.4
-	github.com/go-git/go-billy/v5 v5.1.0
+	github.com/go-git/go-billy/v5 v5.3.1
+	github.com/go-git/go-git/v5 v5.4.2
 	github.com/hashicorp/golang-lru v0.5.4
-	go.etcd.io/bbolt v1.3.5 // indirect
-	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb // indirect
+	github.com/microcosm-cc/bluemonday v1.0.16
+	github.com/yuin/goldmark v1.4.12
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb
 )
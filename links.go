@@ -0,0 +1,65 @@
+package wikithing
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Link is a link from a page to another, written as [[target]] or [[target|label]] in page bodies,
+// the target may also have an #anchor on the end of it
+type Link struct {
+	Target Path
+	Anchor string
+	Label  string
+}
+
+// ParseLink parses the inside of a [[...]] link
+func ParseLink(s string) (l Link, ok bool) {
+	if strings.ContainsAny(s, "[]\n") {
+		return l, false
+	}
+
+	target := s
+	if i := strings.IndexByte(s, '|'); i >= 0 {
+		target, l.Label = s[:i], strings.TrimSpace(s[i+1:])
+	}
+	if i := strings.IndexByte(target, '#'); i >= 0 {
+		target, l.Anchor = target[:i], Slug(target[i+1:])
+	}
+
+	l.Target = ParsePath(target)
+	if l.Target.IsZero() {
+		return l, false
+	}
+	if l.Label == "" {
+		l.Label = strings.TrimSpace(s)
+		if i := strings.IndexByte(l.Label, '|'); i >= 0 {
+			l.Label = strings.TrimSpace(l.Label[:i])
+		}
+	}
+
+	return l, true
+}
+
+// URL gives the address a link goes to on the site
+func (l Link) URL() string {
+	u := "/page/" + l.Target.Path()
+	if l.Anchor != "" {
+		u += "#" + l.Anchor
+	}
+	return u
+}
+
+// Slug lowercases some text and strips it down to just letters, numbers and -, for use as heading ids
+func Slug(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), r == '-':
+			return r
+		case unicode.IsSpace(r):
+			return '-'
+		}
+		return -1
+	}, s)
+}
@@ -1,6 +1,7 @@
 package wikithing
 
 import (
+	"strings"
 	"time"
 
 	"git.lan/wikithing/etc/sid"
@@ -15,6 +16,9 @@ type LogEntry struct {
 	Action uint
 	Reason string
 	When   time.Time
+
+	// Ref is anything the action refers to, for reverts it's the stamp of the revision that was restored
+	Ref string `json:",omitempty"`
 }
 
 // Log action types
@@ -22,6 +26,12 @@ const (
 	LogActionUnspecified = 0
 	LogActionCreate      = 1
 	LogActionEdit        = 2
+	LogActionRevert      = 3
+	LogActionProtect     = 4
+	LogActionUnprotect   = 5
+	LogActionMove        = 6
+	LogActionDelete      = 7
+	LogActionRestore     = 8
 )
 
 // LogActionNames .
@@ -29,4 +39,22 @@ var LogActionNames = map[uint]string{
 	LogActionUnspecified: "Unspecified Action (an error probably)",
 	LogActionCreate:      "Create",
 	LogActionEdit:        "Edit",
+	LogActionRevert:      "Revert",
+	LogActionProtect:     "Protect",
+	LogActionUnprotect:   "Unprotect",
+	LogActionMove:        "Move",
+	LogActionDelete:      "Delete",
+	LogActionRestore:     "Restore",
+}
+
+// MoveRef makes the Ref of a move log entry, the same one goes in the logs at both ends
+func MoveRef(from, to Path) string { return from.String() + " " + to.String() }
+
+// ParseMoveRef gets where a move was from and to out of a log entry's Ref
+func ParseMoveRef(s string) (from, to Path) {
+	i := strings.IndexByte(s, ' ')
+	if i < 0 {
+		return from, ParsePath(s)
+	}
+	return ParsePath(s[:i]), ParsePath(s[i+1:])
 }
@@ -1,7 +1,90 @@
 package wikithing
 
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"git.lan/wikithing/wterr"
+)
+
 type Article struct {
 	Pages []Page
+
+	// Redirect is the path of another article to send people to instead of showing this one
+	Redirect string `json:",omitempty"`
+}
+
+// MaxRedirects is the most redirects that will be followed in a row
+const MaxRedirects = 5
+
+// RedirectTarget gets where an article redirects to, if it does
+func (a Article) RedirectTarget() (Path, bool) {
+	p := ParsePath(a.Redirect)
+	return p, !p.IsZero()
+}
+
+// PageRefPrefix starts the part of a url that picks a page out of an article, like some/article/~runbook
+const PageRefPrefix = "~"
+
+// SplitPageRef splits the page part off of the end of a url path, giving back the rest of it and the page reference without the ~
+func SplitPageRef(s string) (string, string) {
+	i := strings.LastIndex(s, "/"+PageRefPrefix)
+	if i < 0 {
+		if strings.HasPrefix(s, PageRefPrefix) {
+			return "", s[len(PageRefPrefix):]
+		}
+		return s, ""
+	}
+	return s[:i], s[i+1+len(PageRefPrefix):]
+}
+
+// PageSlug gets the name a page in an article is addressed by, which is made from its title,
+// pages without a usable title or with the same one as a page before them are addressed by their number instead
+func (a Article) PageSlug(i int) string {
+	n := strconv.Itoa(i + 1)
+	if i < 0 || i >= len(a.Pages) {
+		return n
+	}
+
+	sl := Slug(a.Pages[i].Title)
+	if sl == "" || isNumber(sl) {
+		return n
+	}
+	for _, x := range a.Pages[:i] {
+		if Slug(x.Title) == sl {
+			return n
+		}
+	}
+	return sl
+}
+
+// FindPage finds a page in an article from a reference to it, either its slug or its number counting from 1
+func (a Article) FindPage(ref string) (int, bool) {
+	if isNumber(ref) {
+		n, err := strconv.Atoi(ref)
+		if err != nil || n < 1 || n > len(a.Pages) {
+			return 0, false
+		}
+		return n - 1, true
+	}
+
+	ref = Slug(ref)
+	for i := range a.Pages {
+		if a.PageSlug(i) == ref {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
 }
 
 type Page struct {
@@ -13,10 +96,115 @@ type Page struct {
 	Format string
 }
 
+// Page formats
+const (
+	FormatMarkdown    = "markdown"
+	FormatFormatthing = "formatthing"
+)
+
+// Formats lists all the formats a page can be written in
+var Formats = []string{FormatMarkdown, FormatFormatthing}
+
+// Table is a set of facts about a page, shown in an infobox next to it
 type Table struct {
-	Fields []string
+	// Title goes at the top of the infobox
+	Title  string  `json:",omitempty"`
+	Fields []Field `json:",omitempty"`
 }
 
+// Field is a single fact in a table, a key with one or more values
 type Field struct {
+	Key  string
 	Rows []string
 }
+
+// Limits on tables
+const (
+	TableMaxFields   = 100
+	TableMaxKeyLen   = 64
+	TableMaxValueLen = 1000
+)
+
+// IsZero checks if a table has nothing in it
+func (t Table) IsZero() bool { return t.Title == "" && len(t.Fields) == 0 }
+
+// Get gets the values of a field by its key, ignoring case
+func (t Table) Get(key string) ([]string, bool) {
+	for _, x := range t.Fields {
+		if strings.EqualFold(x.Key, key) {
+			return x.Rows, true
+		}
+	}
+	return nil, false
+}
+
+// Validate checks that a table is sensible, every field needs a key and at least one value and keys can't repeat
+func (t Table) Validate() error {
+	if len(t.Fields) > TableMaxFields {
+		return wterr.Newf(wterr.ErrInvalidInput, "tables can't have more than %v fields", TableMaxFields)
+	}
+
+	seen := map[string]bool{}
+	for _, x := range t.Fields {
+		k := strings.ToLower(x.Key)
+		switch {
+		case x.Key == "":
+			return wterr.New(wterr.ErrInvalidInput, "every table field needs a key")
+		case len(x.Key) > TableMaxKeyLen:
+			return wterr.Newf(wterr.ErrInvalidInput, "the table key `%v` is too long", x.Key)
+		case seen[k]:
+			return wterr.Newf(wterr.ErrInvalidInput, "the table key `%v` is used more than once", x.Key)
+		case len(x.Rows) == 0:
+			return wterr.Newf(wterr.ErrInvalidInput, "the table field `%v` has no value", x.Key)
+		}
+		seen[k] = true
+
+		for _, v := range x.Rows {
+			if v == "" {
+				return wterr.Newf(wterr.ErrInvalidInput, "the table field `%v` has an empty value", x.Key)
+			}
+			if len(v) > TableMaxValueLen {
+				return wterr.Newf(wterr.ErrInvalidInput, "a value of the table field `%v` is too long", x.Key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalJSON reads a table, tables used to be a list of strings written as "key: value" so those get converted
+func (t *Table) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Title  string
+		Fields json.RawMessage
+	}
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+	t.Title = raw.Title
+	t.Fields = nil
+	if len(raw.Fields) == 0 || string(raw.Fields) == "null" {
+		return nil
+	}
+
+	var fields []Field
+	if err := json.Unmarshal(raw.Fields, &fields); err == nil {
+		t.Fields = fields
+		return nil
+	}
+
+	var old []string
+	err = json.Unmarshal(raw.Fields, &old)
+	if err != nil {
+		return err
+	}
+	for _, x := range old {
+		f := Field{Rows: []string{strings.TrimSpace(x)}}
+		if i := strings.IndexByte(x, ':'); i > 0 {
+			f.Key, f.Rows[0] = strings.TrimSpace(x[:i]), strings.TrimSpace(x[i+1:])
+		}
+		t.Fields = append(t.Fields, f)
+	}
+	return nil
+}
@@ -15,6 +15,9 @@ func (p Path) String() string {
 	return strings.Join(p.p, ".")
 }
 
+// IsZero returns whether or not a path is empty
+func (p Path) IsZero() bool { return len(p.p) == 0 }
+
 const AllowedSpecialChars = "-()"
 
 func ParsePath(s string) Path {
@@ -51,7 +54,80 @@ func ParsePath(s string) Path {
 		p.p = append(p.p, buf.String())
 		buf.Reset()
 	}
-	p.p = append(p.p, buf.String())
+	if buf.Len() != 0 {
+		p.p = append(p.p, buf.String())
+	}
 
 	return p
 }
+
+// Segments gets a copy of the parts of a path
+func (p Path) Segments() []string {
+	return append([]string(nil), p.p...)
+}
+
+// Equal checks if two paths are the same
+func (p Path) Equal(o Path) bool {
+	if len(p.p) != len(o.p) {
+		return false
+	}
+	for i := range p.p {
+		if p.p[i] != o.p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// HasPrefix checks if a path is pre or somewhere underneath it
+func (p Path) HasPrefix(pre Path) bool {
+	if len(pre.p) > len(p.p) {
+		return false
+	}
+	for i := range pre.p {
+		if p.p[i] != pre.p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Child makes the path of something under a path
+func (p Path) Child(name string) Path {
+	c := ParsePath(name)
+	return Path{append(p.Segments(), c.p...)}
+}
+
+// Parent gets the path above a path, the parent of a single segment path is the zero path
+func (p Path) Parent() Path {
+	if len(p.p) == 0 {
+		return p
+	}
+	return Path{p.p[: len(p.p)-1 : len(p.p)-1]}
+}
+
+// Base gets the last segment of a path
+func (p Path) Base() string {
+	if len(p.p) == 0 {
+		return ""
+	}
+	return p.p[len(p.p)-1]
+}
+
+// MatchPattern checks if a path matches a pattern,
+// a pattern is either a path or a path ending in .* which also matches everything under it
+func MatchPattern(pattern string, p Path) bool {
+	_, ok := matchPattern(pattern, p)
+	return ok
+}
+
+// matchPattern is MatchPattern but also giving back how specific the match was
+func matchPattern(pattern string, p Path) (int, bool) {
+	if pre := strings.TrimSuffix(pattern, "*"); pre != pattern {
+		pp := ParsePath(pre)
+		return len(pp.p) * 2, p.HasPrefix(pp)
+	}
+	pp := ParsePath(pattern)
+	// an exact match always wins over a wildcard at the same depth
+	return len(pp.p)*2 + 1, p.Equal(pp)
+}
@@ -0,0 +1,123 @@
+package wikithing
+
+// Permission is something someone can be allowed to do to a page
+type Permission uint
+
+// Permissions that can be checked
+const (
+	PermRead Permission = iota
+	PermEdit
+	// PermAdmin is for things only admins can do, like reverting, deleting and protecting pages
+	PermAdmin
+)
+
+func (p Permission) String() string {
+	switch p {
+	case PermRead:
+		return "read"
+	case PermEdit:
+		return "edit"
+	default:
+		return "administrate"
+	}
+}
+
+// Groups with special meanings
+const (
+	// GroupAdmin can do anything anywhere
+	GroupAdmin = "admin"
+	// GroupUsers is everyone who is logged in, no one needs to be added to it
+	GroupUsers = "users"
+)
+
+// Rule limits who can read or edit a part of the wiki
+type Rule struct {
+	// Pattern is the path the rule is for, ending it with .* makes it cover everything under the path too
+	Pattern string
+
+	// Read and Edit are the groups that are allowed to, if there are none then anyone can
+	Read []string `json:",omitempty"`
+	Edit []string `json:",omitempty"`
+}
+
+// match checks if a rule covers a path, giving back how specific the match was
+func (r Rule) match(p Path) (int, bool) { return matchPattern(r.Pattern, p) }
+
+// Permissions is everything that controls who can do what on the wiki
+type Permissions struct {
+	Rules []Rule `json:",omitempty"`
+
+	// Protected pages can only be edited by admins
+	Protected []string `json:",omitempty"`
+}
+
+// Rule finds the most specific rule covering a path
+func (ps Permissions) Rule(p Path) (Rule, bool) {
+	var best Rule
+	bestN, found := -1, false
+	for _, x := range ps.Rules {
+		n, ok := x.match(p)
+		if ok && n > bestN {
+			best, bestN, found = x, n, true
+		}
+	}
+	return best, found
+}
+
+// IsProtected checks if a page has been protected
+func (ps Permissions) IsProtected(p Path) bool {
+	for _, x := range ps.Protected {
+		if ParsePath(x).Equal(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetProtected protects or unprotects a page
+func (ps *Permissions) SetProtected(p Path, protect bool) {
+	l := ps.Protected[:0:0]
+	for _, x := range ps.Protected {
+		if !ParsePath(x).Equal(p) {
+			l = append(l, x)
+		}
+	}
+	if protect {
+		l = append(l, p.String())
+	}
+	ps.Protected = l
+}
+
+// Allowed checks if a user can do something to a page, u is nil for people who aren't logged in
+func (ps Permissions) Allowed(u *User, p Path, perm Permission) bool {
+	if u.InGroup(GroupAdmin) {
+		return true
+	}
+	if perm == PermAdmin {
+		return false
+	}
+
+	if r, ok := ps.Rule(p); ok {
+		if !inAny(u, r.Read) {
+			return false
+		}
+		if perm == PermEdit && !inAny(u, r.Edit) {
+			return false
+		}
+	}
+
+	return perm != PermEdit || !ps.IsProtected(p)
+}
+
+// inAny checks if a user is in any of a list of groups, an empty list lets anyone in
+func inAny(u *User, groups []string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	for _, x := range groups {
+		if u.InGroup(x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package wikithing
+
+import (
+	"strings"
+	"time"
+
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/wterr"
+)
+
+// MaxCommentLen is the longest a comment can be
+const MaxCommentLen = 10000
+
+// Discussion is where people talk about a page, it belongs to the page at the same path
+type Discussion struct {
+	Comments []Comment `json:",omitempty"`
+}
+
+// Comment is a single comment in a discussion, comments are kept in the order they were posted
+type Comment struct {
+	ID sid.ID
+	// Parent is the comment this is a reply to, it's zero for comments starting a new thread
+	Parent sid.ID `json:",omitempty"`
+	Author sid.ID
+	Body   string
+
+	// Edited is when the comment was last changed, it's zero if it never was
+	Edited time.Time
+	// Deleted comments stay around without their body so replies to them still have somewhere to go
+	Deleted bool `json:",omitempty"`
+}
+
+// Posted is when a comment was posted, which comes from its ID
+func (c Comment) Posted() time.Time { return sid.IDTime(c.ID) }
+
+// Thread is a comment along with all the replies to it
+type Thread struct {
+	Comment
+	Replies []Thread
+}
+
+// Find finds a comment in a discussion by its ID
+func (d Discussion) Find(id sid.ID) (int, bool) {
+	for i, x := range d.Comments {
+		if x.ID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Threads arranges the comments of a discussion into threads, oldest first
+func (d Discussion) Threads() []Thread {
+	replies := make(map[sid.ID][]Comment)
+	var top []Comment
+	for _, x := range d.Comments {
+		if _, ok := d.Find(x.Parent); x.Parent.IsZero() || !ok {
+			top = append(top, x)
+			continue
+		}
+		replies[x.Parent] = append(replies[x.Parent], x)
+	}
+
+	var build func(l []Comment) []Thread
+	build = func(l []Comment) []Thread {
+		out := make([]Thread, 0, len(l))
+		for _, x := range l {
+			out = append(out, Thread{Comment: x, Replies: build(replies[x.ID])})
+		}
+		return out
+	}
+	return build(top)
+}
+
+// ValidComment checks that the body of a comment is something that can be posted
+func ValidComment(body string) error {
+	switch {
+	case strings.TrimSpace(body) == "":
+		return wterr.New(wterr.ErrInvalidInput, "comments can't be empty")
+	case len(body) > MaxCommentLen:
+		return wterr.Newf(wterr.ErrInvalidInput, "comments can't be longer than %v characters", MaxCommentLen)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package wikithing
+
+import (
+	"time"
+	"unicode"
+
+	"git.lan/wikithing/etc/sid"
+)
+
+// User is an account on the wiki
+type User struct {
+	ID   sid.ID
+	Name string
+
+	// PassHash is a bcrypt hash of the user's password
+	PassHash []byte
+
+	Created time.Time
+
+	// Groups are used for deciding what the user is allowed to do
+	Groups []string `json:",omitempty"`
+}
+
+// InGroup checks if a user is in a group, it's safe to call on a nil user
+func (u *User) InGroup(g string) bool {
+	if u == nil {
+		return false
+	}
+	if g == GroupUsers {
+		return true
+	}
+	for _, x := range u.Groups {
+		if x == g {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidGroup checks a group name, they follow the same rules as usernames
+func ValidGroup(s string) bool { return ValidUsername(s) }
+
+// Limits on usernames
+const (
+	UsernameMinLength = 2
+	UsernameMaxLength = 32
+)
+
+// ValidUsername checks that a username is made of only lowercase letters, numbers and -
+// so it can be used as a single path segment
+func ValidUsername(s string) bool {
+	if len(s) < UsernameMinLength || len(s) > UsernameMaxLength {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r), unicode.IsNumber(r), r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
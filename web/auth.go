@@ -0,0 +1,234 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtfs"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+// dummyHash is checked against when logging in as someone who doesn't exist,
+// so it takes about as long as getting the password wrong
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+type authTempl struct {
+	Name  string
+	To    string
+	Error string
+}
+
+// redirectTarget gets where to go after logging in, only allowing paths on this site
+func redirectTarget(r *http.Request) string {
+	to := r.FormValue("to")
+	if !strings.HasPrefix(to, "/") || strings.HasPrefix(to, "//") || strings.HasPrefix(to, "/\\") {
+		return "/"
+	}
+	return to
+}
+
+func (s *Site) showAuth(w http.ResponseWriter, r *http.Request, templ, title string, at authTempl) error {
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, templ, at)
+	if err != nil {
+		return err
+	}
+
+	return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead(title), nil)
+}
+
+func (s *Site) Login(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Login", func() error {
+		return s.showAuth(w, r, "login", "Log in", authTempl{To: redirectTarget(r)})
+	})
+}
+
+func (s *Site) LoginPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Login-Post", func() error {
+		name := strings.ToLower(strings.TrimSpace(r.PostFormValue("name")))
+		at := authTempl{Name: name, To: redirectTarget(r)}
+
+		u, err := s.checkLogin(name, r.PostFormValue("password"))
+		if e, ok := err.(wterr.Err); ok && e.Type == wterr.ErrAuthFailed {
+			at.Error = e.Str
+			w.WriteHeader(http.StatusForbidden)
+			return s.showAuth(w, r, "login", "Log in", at)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.startSession(w, u)
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, at.To, http.StatusSeeOther)
+		return nil
+	})
+}
+
+// checkLogin checks a username and password, failing with wterr.ErrAuthFailed if they're wrong
+func (s *Site) checkLogin(name, pass string) (wikithing.User, error) {
+	u, err := s.Wiki.LoadUser(name)
+	if os.IsNotExist(err) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(pass))
+		return u, wterr.New(wterr.ErrAuthFailed, "incorrect username or password")
+	}
+	if err != nil {
+		return u, err
+	}
+
+	if bcrypt.CompareHashAndPassword(u.PassHash, []byte(pass)) != nil {
+		return u, wterr.New(wterr.ErrAuthFailed, "incorrect username or password")
+	}
+	return u, nil
+}
+
+func (s *Site) LogoutPost(w http.ResponseWriter, r *http.Request) {
+	s.endSession(w, r)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Site) Register(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Register", func() error {
+		return s.showAuth(w, r, "register", "Register", authTempl{To: redirectTarget(r)})
+	})
+}
+
+func (s *Site) RegisterPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Register-Post", func() error {
+		name := strings.ToLower(strings.TrimSpace(r.PostFormValue("name")))
+		pass := r.PostFormValue("password")
+		at := authTempl{Name: name, To: redirectTarget(r)}
+
+		fail := func(msg string) error {
+			at.Error = msg
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showAuth(w, r, "register", "Register", at)
+		}
+
+		switch {
+		case !wikithing.ValidUsername(name):
+			return fail("usernames have to be 2 to 32 characters of only letters, numbers and -")
+		case len(pass) < minPasswordLength:
+			return fail("passwords have to be at least 8 characters long")
+		case pass != r.PostFormValue("confirm"):
+			return fail("the passwords don't match")
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		u := wikithing.User{
+			ID:       sid.Get(),
+			Name:     name,
+			PassHash: hash,
+			Created:  time.Now().UTC(),
+		}
+		// nobody gets to be an admin by registering, the first one is set up with MakeAdmin
+		err = s.Wiki.CreateUser(u, wikithing.LogEntry{
+			Actor:  u.ID,
+			Reason: "Registered",
+		})
+		if os.IsExist(err) {
+			return fail("that username is already taken")
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.startSession(w, u)
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, at.To, http.StatusSeeOther)
+		return nil
+	})
+}
+
+// MakeAdmin puts a user in the admin group, creating them with password if they don't exist yet (the password is ignored if they do),
+// it's how the first admin gets set up since registering never makes anyone one
+func MakeAdmin(store wtfs.Store, name, password string) (created bool, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	why := wikithing.LogEntry{Reason: "Made an admin from the command line"}
+
+	u, err := store.LoadUser(name)
+	if err == nil {
+		if u.InGroup(wikithing.GroupAdmin) {
+			return false, nil
+		}
+		u.Groups = append(u.Groups, wikithing.GroupAdmin)
+		return false, store.SaveUser(u, why)
+	}
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	switch {
+	case !wikithing.ValidUsername(name):
+		return false, wterr.New(wterr.ErrInvalidInput, "usernames have to be 2 to 32 characters of only letters, numbers and -")
+	case len(password) < minPasswordLength:
+		return false, wterr.New(wterr.ErrInvalidInput, "passwords have to be at least 8 characters long")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return false, err
+	}
+	u = wikithing.User{
+		ID:       sid.Get(),
+		Name:     name,
+		PassHash: hash,
+		Created:  time.Now().UTC(),
+		Groups:   []string{wikithing.GroupAdmin},
+	}
+	why.Actor = u.ID
+	return true, store.CreateUser(u, why)
+}
+
+// userNames caches the names of users by their ID, since looking them up means going through every user
+type userNames struct {
+	m     sync.RWMutex
+	names map[sid.ID]string
+}
+
+// actorName gets something to show for who did something
+func (s *Site) actorName(le wikithing.LogEntry) string { return s.userName(le.Actor) }
+
+// userName gets the name of a user from their ID
+func (s *Site) userName(id sid.ID) string {
+	if id.IsZero() {
+		return "anonymous"
+	}
+
+	s.names.m.RLock()
+	n, ok := s.names.names[id]
+	s.names.m.RUnlock()
+	if ok {
+		return n
+	}
+
+	u, err := s.Wiki.UserByID(id)
+	if err != nil {
+		return id.String()
+	}
+
+	s.names.m.Lock()
+	s.names.names[id] = u.Name
+	s.names.m.Unlock()
+
+	return u.Name
+}
@@ -0,0 +1,67 @@
+package web_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/web"
+)
+
+func TestRegisterNotAdmin(t *testing.T) {
+	srv, store := newSite(t)
+
+	res, err := client.PostForm(srv.URL+"/register", url.Values{
+		"name":     {"first"},
+		"password": {"password1"},
+		"confirm":  {"password1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("got %v registering", res.StatusCode)
+	}
+
+	u, err := store.LoadUser("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.InGroup(wikithing.GroupAdmin) {
+		t.Error("the first user to register was made an admin")
+	}
+
+	created, err := web.MakeAdmin(store, "first", "")
+	if err != nil || created {
+		t.Fatalf("promoting an existing user gave %v %v", created, err)
+	}
+	u, err = store.LoadUser("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.InGroup(wikithing.GroupAdmin) {
+		t.Error("MakeAdmin didn't make an existing user an admin")
+	}
+}
+
+func TestMakeAdminCreates(t *testing.T) {
+	_, store := newSite(t)
+
+	if _, err := web.MakeAdmin(store, "boss", "short"); err == nil {
+		t.Error("an admin was made with a short password")
+	}
+
+	created, err := web.MakeAdmin(store, "boss", "password1")
+	if err != nil || !created {
+		t.Fatalf("creating an admin gave %v %v", created, err)
+	}
+	u, err := store.LoadUser("boss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.InGroup(wikithing.GroupAdmin) {
+		t.Error("the created user isn't an admin")
+	}
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+// subpageDepth is how many levels of subpages are shown in the sidebar of a page
+const subpageDepth = 2
+
+type crumb struct {
+	Path   wikithing.Path
+	Exists bool
+}
+
+// breadcrumbs lists every page above a path, from the top down
+func (s *Site) breadcrumbs(p wikithing.Path) []crumb {
+	var out []crumb
+	for x := p.Parent(); !x.IsZero(); x = x.Parent() {
+		out = append([]crumb{{Path: x, Exists: s.Links.Exists(x)}}, out...)
+	}
+	return out
+}
+
+// children lists what's under a page that whoever made a request can see
+func (s *Site) children(r *http.Request, p wikithing.Path) ([]wtfs.Child, error) {
+	l, err := s.Wiki.ListChildren(p)
+	if err != nil {
+		return nil, err
+	}
+
+	u := s.CurrentUser(r)
+	ps := s.Permissions()
+
+	out := l[:0]
+	for _, x := range l {
+		if ps.Allowed(u, x.Path, wikithing.PermRead) {
+			out = append(out, x)
+		}
+	}
+	return out, nil
+}
+
+type browseTempl struct {
+	Path     wikithing.Path
+	Crumbs   []crumb
+	Exists   bool
+	Children []wtfs.Child
+}
+
+func (s *Site) Browse(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Browse", func() error {
+		p := pagePath(r)
+		if !p.IsZero() {
+			if err := s.checkPerm(r, p, wikithing.PermRead); err != nil {
+				return err
+			}
+		}
+
+		l, err := s.children(r, p)
+		if err != nil {
+			return err
+		}
+
+		buf := &strings.Builder{}
+		err = s.templates.ExecuteTemplate(buf, "browse", browseTempl{
+			Path:     p,
+			Crumbs:   s.breadcrumbs(p),
+			Exists:   s.Links.Exists(p),
+			Children: l,
+		})
+		if err != nil {
+			return err
+		}
+
+		title := "All pages"
+		if !p.IsZero() {
+			title = "Pages under " + p.String()
+		}
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead(title), nil)
+	})
+}
+
+type subpageTree struct {
+	wtfs.Child
+	Children []subpageTree
+	// More is set when there's more under this than is shown
+	More bool
+}
+
+// subpages builds the tree of pages under a page, going depth levels down
+func (s *Site) subpages(r *http.Request, p wikithing.Path, depth int) ([]subpageTree, error) {
+	l, err := s.children(r, p)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]subpageTree, len(l))
+	for i, x := range l {
+		out[i].Child = x
+		if !x.HasChildren {
+			continue
+		}
+		if depth <= 1 {
+			out[i].More = true
+			continue
+		}
+		out[i].Children, err = s.subpages(r, x.Path, depth-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// subpagesSidebar is the part of the sidebar of a page listing what's under it, it's nil if there's nothing
+func (s *Site) subpagesSidebar(r *http.Request, p wikithing.Path) *Sidebar {
+	tree, err := s.subpages(r, p, subpageDepth)
+	if err != nil {
+		log.Println("!", err)
+		return nil
+	}
+	if len(tree) == 0 {
+		return nil
+	}
+
+	buf := &strings.Builder{}
+	err = s.templates.ExecuteTemplate(buf, "subpagessidebar", tree)
+	if err != nil {
+		log.Println("!", err)
+		return nil
+	}
+
+	return &Sidebar{Title: "Subpages", Content: template.HTML(buf.String())}
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtdiff"
+)
+
+type editConflict struct {
+	// Entry and Actor are about the save that got in first
+	Entry wikithing.LogEntry
+	Actor string
+
+	Chunks []wtdiff.MergeChunk
+	// Clean is set if the body merged without any conflicts
+	Clean bool
+	// Fields are the other parts of the page both sides changed, the version being saved is what's kept in the form
+	Fields []conflictField
+}
+
+type conflictField struct {
+	Name   string
+	Theirs string
+	// Table is set instead of Theirs for the infobox
+	Table *wikithing.Table
+}
+
+// showConflict shows the editor again after someone else saved the page first,
+// with the changes merged together as far as they can be and the rest marked for sorting out by hand
+func (s *Site) showConflict(w http.ResponseWriter, r *http.Request, et editorTempl) error {
+	stamp, err := s.Wiki.PageStamp(et.Path)
+	if err != nil {
+		return err
+	}
+	cur, err := s.Wiki.LoadPage(et.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var base wikithing.Article
+	if et.Base != "" {
+		at, err := parseRevision(et.Base)
+		if err != nil {
+			return err
+		}
+		base, err = s.Wiki.LoadPageRevision(et.Path, at)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	ref := pageRef(r)
+	bp, cp := pageOf(base, ref), pageOf(cur, ref)
+
+	m := wtdiff.Merge3(bp.Body, et.Page.Body, cp.Body)
+	ec := &editConflict{
+		Chunks: m.Chunks,
+		Clean:  !m.Conflicts(),
+	}
+	if revs, err := s.Wiki.PageRevisions(et.Path); err == nil && len(revs) > 0 {
+		ec.Entry = revs[len(revs)-1].LogEntry
+		ec.Actor = s.actorName(ec.Entry)
+	}
+
+	// everything besides the body is merged as a whole,
+	// taking their change if this edit didn't touch it and keeping this edit's if both did
+	field := func(name, base, ours, theirs string) string {
+		switch {
+		case ours == base:
+			return theirs
+		case theirs != base && theirs != ours:
+			ec.Fields = append(ec.Fields, conflictField{Name: name, Theirs: theirs})
+		}
+		return ours
+	}
+	et.Page.Body = m.Text()
+	et.Page.Title = field("Title", bp.Title, et.Page.Title, cp.Title)
+	et.Page.Format = field("Format", bp.Format, et.Page.Format, cp.Format)
+	et.Redirect = field("Redirect", base.Redirect, et.Redirect, cur.Redirect)
+	switch {
+	case reflect.DeepEqual(et.Page.Table, bp.Table):
+		et.Page.Table = cp.Table
+	case !reflect.DeepEqual(cp.Table, bp.Table) && !reflect.DeepEqual(cp.Table, et.Page.Table):
+		t := cp.Table
+		ec.Fields = append(ec.Fields, conflictField{Name: "Infobox", Table: &t})
+	}
+
+	et.Base = stamp
+	et.Conflict = ec
+	w.WriteHeader(http.StatusConflict)
+	return s.showEditor(w, r, et)
+}
+
+// pageOf picks a page out of an article by a reference from a url, giving an empty page if it isn't there
+func pageOf(a wikithing.Article, ref string) wikithing.Page {
+	i, ok := 0, len(a.Pages) > 0
+	if ref != "" {
+		i, ok = a.FindPage(ref)
+	}
+	if !ok {
+		return wikithing.Page{}
+	}
+	return a.Pages[i]
+}
+
+// hasConflictMarkers checks if some text still has the markers from a merge conflict in it
+func hasConflictMarkers(s string) bool {
+	for _, x := range strings.Split(s, "\n") {
+		if x == wtdiff.MarkerOurs || x == wtdiff.MarkerTheirs {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtfs"
+)
+
+type deleteTempl struct {
+	Path  wikithing.Path
+	Media bool
+}
+
+func (s *Site) Delete(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Delete-Page", func() error {
+		p := pagePath(r)
+		if err := s.checkPerm(r, p, wikithing.PermAdmin); err != nil {
+			return err
+		}
+		if !s.Links.Exists(p) {
+			return s.PageNotFound(w, r, p)
+		}
+
+		buf := &strings.Builder{}
+		err := s.templates.ExecuteTemplate(buf, "delete", deleteTempl{Path: p})
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Deleting "+p.String()), nil)
+	})
+}
+
+func (s *Site) DeletePost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Delete-Page-Post", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no page given to delete")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermAdmin); err != nil {
+			return err
+		}
+
+		summary := strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("summary")))
+		if summary == "" {
+			return wterr.New(wterr.ErrInvalidInput, "a reason for deleting the page is required")
+		}
+
+		err := s.Wiki.DeletePage(p, s.logEntry(r, summary))
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, "/admin/trash", http.StatusSeeOther)
+		return nil
+	})
+}
+
+func (s *Site) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Delete-Media", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+		p := pagePath(r)
+		if _, err := s.Wiki.LoadMedia(p); err != nil {
+			if os.IsNotExist(err) {
+				return wterr.Newf(wterr.ErrInvalidInput, "there's no media at %v", p)
+			}
+			return err
+		}
+
+		buf := &strings.Builder{}
+		err := s.templates.ExecuteTemplate(buf, "delete", deleteTempl{Path: p, Media: true})
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Deleting "+p.String()), nil)
+	})
+}
+
+func (s *Site) DeleteMediaPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Delete-Media-Post", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no media given to delete")
+		}
+
+		summary := strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("summary")))
+		if summary == "" {
+			return wterr.New(wterr.ErrInvalidInput, "a reason for deleting the media is required")
+		}
+
+		err := s.Wiki.DeleteMedia(p, s.logEntry(r, summary))
+		if os.IsNotExist(err) {
+			return wterr.Newf(wterr.ErrInvalidInput, "there's no media at %v", p)
+		}
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, "/admin/trash", http.StatusSeeOther)
+		return nil
+	})
+}
+
+type trashTempl struct {
+	Pages []trashEntry
+	Media []trashEntry
+}
+
+type trashEntry struct {
+	wtfs.Deleted
+	Actor string
+}
+
+func (s *Site) AdminTrash(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Admin-Trash", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+
+		pages, err := s.Wiki.DeletedPages()
+		if err != nil {
+			return err
+		}
+		media, err := s.Wiki.DeletedMedia()
+		if err != nil {
+			return err
+		}
+
+		tt := trashTempl{}
+		for _, x := range pages {
+			tt.Pages = append(tt.Pages, trashEntry{Deleted: x, Actor: s.actorName(x.Entry)})
+		}
+		for _, x := range media {
+			tt.Media = append(tt.Media, trashEntry{Deleted: x, Actor: s.actorName(x.Entry)})
+		}
+
+		buf := &strings.Builder{}
+		err = s.templates.ExecuteTemplate(buf, "admintrash", tt)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Trash"), nil)
+	})
+}
+
+func (s *Site) AdminRestorePost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Admin-Restore-Post", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+
+		p := wikithing.ParsePath(r.PostFormValue("path"))
+		stamp := r.PostFormValue("stamp")
+		le := s.logEntry(r, "Restored from the trash")
+
+		var err error
+		switch r.PostFormValue("kind") {
+		case "page":
+			err = s.Wiki.RestorePage(p, stamp, le)
+		case "media":
+			err = s.Wiki.RestoreMedia(p, stamp, le)
+		default:
+			return wterr.Newf(wterr.ErrInvalidInput, "can't restore `%v`", r.PostFormValue("kind"))
+		}
+		if os.IsExist(err) {
+			return wterr.Newf(wterr.ErrInvalidInput, "there's already something at %v, move it out of the way first", p)
+		}
+		if os.IsNotExist(err) {
+			return wterr.Newf(wterr.ErrInvalidInput, "%v from %v isn't in the trash", p, stamp)
+		}
+		if err != nil {
+			return err
+		}
+
+		if r.PostFormValue("kind") == "page" {
+			http.Redirect(w, r, "/page/"+p.Path(), http.StatusSeeOther)
+			return nil
+		}
+		http.Redirect(w, r, "/admin/trash", http.StatusSeeOther)
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/web"
+)
+
+func TestDeleteMedia(t *testing.T) {
+	srv, store := newSite(t)
+	loc := wikithing.ParsePath("cat")
+
+	err := store.SaveMedia(loc, wikithing.FileObject{ID: sid.Get(), FileConfig: wikithing.FileConfig{Class: wikithing.FileClassImage, Hash: "abc"}}, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	del := func(c *http.Client) int {
+		t.Helper()
+		res, err := c.PostForm(srv.URL+"/delete-media/cat", url.Values{"summary": {"not wanted"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if code := del(client); code != http.StatusForbidden {
+		t.Errorf("got %v deleting media without logging in", code)
+	}
+	if _, err := store.LoadMedia(loc); err != nil {
+		t.Fatalf("the media was deleted by someone who isn't an admin: %v", err)
+	}
+
+	if _, err := web.MakeAdmin(store, "boss", "password1"); err != nil {
+		t.Fatal(err)
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &http.Client{Jar: jar, CheckRedirect: client.CheckRedirect}
+	res, err := admin.PostForm(srv.URL+"/login", url.Values{"name": {"boss"}, "password": {"password1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	res, err = admin.Get(srv.URL + "/delete-media/cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("got %v asking to delete media", res.StatusCode)
+	}
+
+	if code := del(admin); code != http.StatusSeeOther {
+		t.Fatalf("got %v deleting media", code)
+	}
+	if _, err := store.LoadMedia(loc); !os.IsNotExist(err) {
+		t.Errorf("the media is still there after deleting it (%v)", err)
+	}
+	l, err := store.DeletedMedia()
+	if err != nil || len(l) != 1 || !l[0].Path.Equal(loc) {
+		t.Errorf("got %v %v from the trash", l, err)
+	}
+}
@@ -0,0 +1,142 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtdiff"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtfs"
+)
+
+// diffContext is how many unchanged lines are shown around changes
+const diffContext = 3
+
+type diffSide struct {
+	// Stamp is empty for the side before a page existed
+	Stamp   string
+	Current bool
+	Entry   wikithing.LogEntry
+	Actor   string
+}
+
+type diffPage struct {
+	Index int
+	Title []wtdiff.Edit
+	Body  [][]wtdiff.Line
+	Table [][]wtdiff.Line
+
+	FormatFrom string
+	FormatTo   string
+}
+
+type diffTempl struct {
+	Path     wikithing.Path
+	From, To diffSide
+
+	Changed bool
+	Pages   []diffPage
+}
+
+func (s *Site) Diff(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Page-Diff", func() error {
+		p := pagePath(r)
+		if err := s.checkPerm(r, p, wikithing.PermRead); err != nil {
+			return err
+		}
+
+		revs, err := s.Wiki.PageRevisions(p)
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+		if len(revs) == 0 {
+			return s.PageNotFound(w, r, p)
+		}
+
+		to := len(revs) - 1
+		if x := r.URL.Query().Get("to"); x != "" {
+			to, err = findRevision(revs, x)
+			if err != nil {
+				return err
+			}
+		}
+		from := to - 1
+		if x := r.URL.Query().Get("from"); x != "" {
+			from, err = findRevision(revs, x)
+			if err != nil {
+				return err
+			}
+		}
+
+		dt := diffTempl{Path: p}
+
+		var a, b wikithing.Article
+		if from >= 0 {
+			a, err = s.Wiki.LoadPageRevision(p, revs[from].When)
+			if err != nil {
+				return err
+			}
+			dt.From = s.diffSide(revs[from])
+		}
+		b, err = s.Wiki.LoadPageRevision(p, revs[to].When)
+		if err != nil {
+			return err
+		}
+		dt.To = s.diffSide(revs[to])
+
+		d := wtdiff.Articles(a, b)
+		dt.Changed = d.Changed()
+		for i, x := range d.Pages {
+			if !x.Changed() {
+				continue
+			}
+			dt.Pages = append(dt.Pages, diffPage{
+				Index:      i + 1,
+				Title:      x.Title,
+				Body:       wtdiff.Hunks(x.Body, diffContext),
+				Table:      wtdiff.Hunks(x.Table, diffContext),
+				FormatFrom: x.FormatFrom,
+				FormatTo:   x.FormatTo,
+			})
+		}
+
+		buf := &strings.Builder{}
+		err = s.templates.ExecuteTemplate(buf, "diff", dt)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Changes to "+p.String()), nil)
+	})
+}
+
+func (s *Site) diffSide(r wtfs.Revision) diffSide {
+	return diffSide{
+		Stamp:   r.Stamp,
+		Current: r.Current,
+		Entry:   r.LogEntry,
+		Actor:   s.actorName(r.LogEntry),
+	}
+}
+
+// findRevision finds the position of a revision from a url in a list of them
+func findRevision(revs []wtfs.Revision, stamp string) (int, error) {
+	at, err := parseRevision(stamp)
+	if err != nil {
+		return 0, err
+	}
+	stamp = at.Format(wtfs.TimeFormat)
+
+	for i, x := range revs {
+		if x.Stamp == stamp {
+			return i, nil
+		}
+	}
+	return 0, wterr.Newf(wterr.ErrInvalidInput, "there is no revision `%v`", stamp)
+}
@@ -0,0 +1,276 @@
+package web
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtfs"
+)
+
+// maxEditSize is the largest form body accepted when saving a page
+const maxEditSize = 4 << 20
+
+type editorTempl struct {
+	Path wikithing.Path
+	New  bool
+	// Ref picks out which page of the article is being edited, it's empty for the first
+	Ref string
+
+	Page     wikithing.Page
+	Redirect string
+	Summary  string
+	Formats  []string
+	// Base is the stamp of the revision the edit started from, for noticing if someone else saved in the meantime
+	Base string
+
+	Error string
+	// Conflict is set when someone else saved the page while it was being edited
+	Conflict *editConflict
+
+	// Blank is how many empty table fields to add on to the end of the form
+	Blank int
+	// Fields are the table fields shown in the form, including the blank ones
+	Fields []wikithing.Field
+}
+
+// editorBlankFields is how many empty table fields there always are for adding new ones
+const editorBlankFields = 2
+
+func (s *Site) Edit(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Edit-Page", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no page given to edit")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermEdit); err != nil {
+			return err
+		}
+
+		et := editorTempl{
+			Path: p,
+			Page: wikithing.Page{Format: wikithing.FormatMarkdown},
+		}
+
+		var err error
+		// the stamp has to come first, if the page is saved in between it just looks like a conflict later
+		et.Base, err = s.Wiki.PageStamp(p)
+		if err != nil {
+			return err
+		}
+		a, err := s.Wiki.LoadPage(p)
+		switch {
+		case os.IsNotExist(err):
+			et.New = true
+		case err != nil:
+			return err
+		case len(a.Pages) > 0:
+			sel, err := findPage(r, a)
+			if err != nil {
+				return err
+			}
+			et.Page = a.Pages[sel]
+			et.Ref = refSuffix(a, sel)
+		}
+		et.Redirect = a.Redirect
+
+		return s.showEditor(w, r, et)
+	})
+}
+
+func (s *Site) EditPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Edit-Page-Post", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no page given to edit")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermEdit); err != nil {
+			return err
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxEditSize)
+		if err := r.ParseForm(); err != nil {
+			return wterr.New(wterr.ErrInvalidInput, err)
+		}
+
+		pg := wikithing.Page{
+			Title:  strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("title"))),
+			Body:   normaliseBody(r.PostFormValue("body")),
+			Format: r.PostFormValue("format"),
+			Table:  parseTable(r),
+		}
+		summary := strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("summary")))
+
+		a, err := s.Wiki.LoadPage(p)
+		isNew := os.IsNotExist(err)
+		if err != nil && !isNew {
+			return err
+		}
+		sel, err := findPage(r, a)
+		if err != nil && !isNew {
+			return err
+		}
+
+		et := editorTempl{
+			Path:     p,
+			New:      isNew,
+			Ref:      refSuffix(a, sel),
+			Page:     pg,
+			Redirect: strings.TrimSpace(r.PostFormValue("redirect")),
+			Summary:  summary,
+			Base:     r.PostFormValue("base"),
+		}
+		// adding a field to the table just shows the editor again with room for one more
+		if r.PostFormValue("addfield") != "" {
+			et.Blank, _ = strconv.Atoi(r.PostFormValue("blank"))
+			et.Blank++
+			return s.showEditor(w, r, et)
+		}
+		if !validFormat(pg.Format) {
+			et.Error = "unknown page format: " + pg.Format
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showEditor(w, r, et)
+		}
+		if summary == "" {
+			et.Error = "an edit summary is required"
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showEditor(w, r, et)
+		}
+		if hasConflictMarkers(pg.Body) {
+			et.Error = "the body still has conflict markers in it, pick which side to keep and take them out"
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showEditor(w, r, et)
+		}
+		if err := pg.Table.Validate(); err != nil {
+			et.Error = err.(wterr.Err).Str
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showEditor(w, r, et)
+		}
+		redir, err := s.checkRedirect(p, et.Redirect)
+		if err != nil {
+			et.Error = err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showEditor(w, r, et)
+		}
+		a.Redirect = redir
+
+		if len(a.Pages) == 0 {
+			a.Pages = append(a.Pages, pg)
+		} else {
+			a.Pages[sel] = pg
+		}
+
+		err = s.Wiki.SavePage(p, a, et.Base, s.logEntry(r, summary))
+		if err == wtfs.ErrConflict {
+			return s.showConflict(w, r, et)
+		}
+		if err != nil {
+			return err
+		}
+
+		// the page might have a different slug now if its title changed
+		http.Redirect(w, r, "/page/"+p.Path()+refSuffix(a, sel), http.StatusSeeOther)
+		return nil
+	})
+}
+
+func (s *Site) showEditor(w http.ResponseWriter, r *http.Request, et editorTempl) error {
+	et.Formats = wikithing.Formats
+	et.Fields = append([]wikithing.Field(nil), et.Page.Table.Fields...)
+	for i := 0; i < et.Blank+editorBlankFields; i++ {
+		et.Fields = append(et.Fields, wikithing.Field{})
+	}
+
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, "editor", et)
+	if err != nil {
+		return err
+	}
+
+	title := "Editing " + et.Path.String()
+	if et.New {
+		title = "Creating " + et.Path.String()
+	}
+	return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead(title), nil)
+}
+
+// checkRedirect makes sure a page can redirect to a target without making a loop or too long of a chain,
+// giving back the target in the form it's stored in
+func (s *Site) checkRedirect(p wikithing.Path, target string) (string, error) {
+	if strings.TrimSpace(target) == "" {
+		return "", nil
+	}
+	t := wikithing.ParsePath(target)
+	if t.IsZero() {
+		return "", errors.New("invalid redirect target " + target)
+	}
+
+	chain, err := s.Wiki.FollowRedirects(t)
+	for _, x := range chain {
+		if x.Equal(p) {
+			return "", errors.New("redirecting to " + t.String() + " would make a redirect loop")
+		}
+	}
+	if err == wtfs.ErrRedirectLoop {
+		return "", errors.New(t.String() + " is part of a redirect loop")
+	}
+	if err == wtfs.ErrTooManyRedirects || len(chain) >= wikithing.MaxRedirects {
+		return "", errors.New("redirecting to " + t.String() + " would make too long of a chain of redirects")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return t.String(), nil
+}
+
+func validFormat(f string) bool {
+	for _, x := range wikithing.Formats {
+		if x == f {
+			return true
+		}
+	}
+	return false
+}
+
+// normaliseBody cleans up line endings and any stray control characters in a page body while keeping the newlines
+func normaliseBody(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	for i, x := range lines {
+		lines[i] = wikithing.NormaliseText(strings.ReplaceAll(x, "\t", "    "))
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+}
+
+// parseTable takes the table part of the editor form, each field has a key input and a textarea with one value per line,
+// fields left completely empty are dropped
+func parseTable(r *http.Request) wikithing.Table {
+	t := wikithing.Table{
+		Title: strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("table-title"))),
+	}
+
+	keys, values := r.PostForm["table-key"], r.PostForm["table-value"]
+	for i, k := range keys {
+		f := wikithing.Field{Key: strings.TrimSpace(wikithing.NormaliseText(k))}
+		if i < len(values) {
+			for _, v := range strings.Split(values[i], "\n") {
+				v = strings.TrimSpace(wikithing.NormaliseText(v))
+				if v != "" {
+					f.Rows = append(f.Rows, v)
+				}
+			}
+		}
+		if f.Key == "" && len(f.Rows) == 0 {
+			continue
+		}
+		t.Fields = append(t.Fields, f)
+	}
+
+	return t
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtfs"
+)
+
+type historyEntry struct {
+	wikithing.LogEntry
+
+	ActionName string
+	ActorName  string
+	// RevertedTo is the revision a revert went back to
+	RevertedTo string
+	// MovedFrom and MovedTo are set for moves
+	MovedFrom, MovedTo wikithing.Path
+	// Stamp is set if the entry made a revision that can be looked at
+	Stamp   string
+	Current bool
+}
+
+type historyTempl struct {
+	Path    wikithing.Path
+	Entries []historyEntry
+
+	// CanRevert is whether to show links for reverting
+	CanRevert bool
+}
+
+func (s *Site) History(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Page-History", func() error {
+		p := pagePath(r)
+		if err := s.checkPerm(r, p, wikithing.PermRead); err != nil {
+			return err
+		}
+
+		l, err := s.Wiki.PageLog(p)
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+
+		ht := historyTempl{
+			Path:    p,
+			Entries: make([]historyEntry, 0, len(l.Entries)),
+
+			CanRevert: s.can(r, p, wikithing.PermAdmin),
+		}
+
+		// newest first
+		last := true
+		for i := len(l.Entries) - 1; i >= 0; i-- {
+			x := l.Entries[i]
+			he := historyEntry{
+				LogEntry:   x,
+				ActionName: actionName(x.Action),
+				ActorName:  s.actorName(x),
+			}
+			switch x.Action {
+			case wikithing.LogActionRevert:
+				he.RevertedTo = x.Ref
+			case wikithing.LogActionMove:
+				he.MovedFrom, he.MovedTo = wikithing.ParseMoveRef(x.Ref)
+			}
+			if wtfs.ChangesContent(x.Action) {
+				he.Stamp = x.When.Format(wtfs.TimeFormat)
+				he.Current = last
+				last = false
+			}
+			ht.Entries = append(ht.Entries, he)
+		}
+
+		buf := &strings.Builder{}
+		err = s.templates.ExecuteTemplate(buf, "history", ht)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("History of "+p.String()), nil)
+	})
+}
+
+// actionName gets the readable name of a log action
+func actionName(a uint) string {
+	if n, ok := wikithing.LogActionNames[a]; ok {
+		return n
+	}
+	return wikithing.LogActionNames[wikithing.LogActionUnspecified]
+}
+
+// parseRevision parses a revision stamp from a url
+func parseRevision(s string) (time.Time, error) {
+	t, err := time.Parse(wtfs.TimeFormat, s)
+	if err != nil {
+		return t, wterr.Newf(wterr.ErrInvalidInput, "invalid revision `%v`", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtlinks"
+)
+
+// indexPage is the front page of the wiki
+var indexPage = wikithing.ParsePath("index")
+
+// sidebarLinks is how many backlinks are shown in the sidebar of a page
+const sidebarLinks = 15
+
+// readable filters a list of pages down to the ones whoever made a request can see
+func (s *Site) readable(r *http.Request, l []wikithing.Path) []wikithing.Path {
+	u := s.CurrentUser(r)
+	ps := s.Permissions()
+
+	out := l[:0:0]
+	for _, x := range l {
+		if ps.Allowed(u, x, wikithing.PermRead) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+type backlinksSidebarTempl struct {
+	Path  wikithing.Path
+	Links []wikithing.Path
+	More  bool
+}
+
+// pageSidebar is the sidebar shown next to a page, with what's under it and what links to it
+func (s *Site) pageSidebar(r *http.Request, p wikithing.Path) []Sidebar {
+	bt := backlinksSidebarTempl{
+		Path:  p,
+		Links: s.readable(r, s.Links.Inbound(p)),
+	}
+	if len(bt.Links) > sidebarLinks {
+		bt.Links, bt.More = bt.Links[:sidebarLinks], true
+	}
+
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, "backlinkssidebar", bt)
+	if err != nil {
+		log.Println("!", err)
+	}
+
+	out := []Sidebar{s.DefaultSidebar()}
+	if sp := s.subpagesSidebar(r, p); sp != nil {
+		out = append(out, *sp)
+	}
+	return append(out, Sidebar{Title: "What links here", Content: template.HTML(buf.String())})
+}
+
+type backlinksTempl struct {
+	Path     wikithing.Path
+	Exists   bool
+	Inbound  []wikithing.Path
+	Outbound []linkTarget
+}
+
+type linkTarget struct {
+	Path   wikithing.Path
+	Exists bool
+}
+
+func (s *Site) Backlinks(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Backlinks", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no page given to find links to")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermRead); err != nil {
+			return err
+		}
+
+		bt := backlinksTempl{
+			Path:    p,
+			Exists:  s.Links.Exists(p),
+			Inbound: s.readable(r, s.Links.Inbound(p)),
+		}
+		for _, x := range s.readable(r, s.Links.Outbound(p)) {
+			bt.Outbound = append(bt.Outbound, linkTarget{Path: x, Exists: s.Links.Exists(x)})
+		}
+
+		buf := &strings.Builder{}
+		err := s.templates.ExecuteTemplate(buf, "backlinks", bt)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Links to "+p.String()), s.pageSidebar(r, p))
+	})
+}
+
+type wantedTempl struct {
+	Pages []wtlinks.Wanted
+}
+
+func (s *Site) WantedPages(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Wanted-Pages", func() error {
+		wt := wantedTempl{}
+		for _, x := range s.Links.Wanted() {
+			if !s.can(r, x.Path, wikithing.PermRead) {
+				continue
+			}
+			x.From = s.readable(r, x.From)
+			if len(x.From) == 0 {
+				continue
+			}
+			wt.Pages = append(wt.Pages, x)
+		}
+
+		buf := &strings.Builder{}
+		err := s.templates.ExecuteTemplate(buf, "wanted", wt)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Wanted pages"), nil)
+	})
+}
+
+type orphansTempl struct {
+	Pages []wikithing.Path
+}
+
+func (s *Site) OrphanedPages(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Orphaned-Pages", func() error {
+		ot := orphansTempl{}
+		for _, x := range s.readable(r, s.Links.Orphans()) {
+			// the front page doesn't need anything linking to it
+			if x.Equal(indexPage) {
+				continue
+			}
+			ot.Pages = append(ot.Pages, x)
+		}
+
+		buf := &strings.Builder{}
+		err := s.templates.ExecuteTemplate(buf, "orphans", ot)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Orphaned pages"), nil)
+	})
+}
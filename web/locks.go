@@ -0,0 +1,89 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtfs"
+)
+
+// locker gets the lockfiles of the store, if it has any
+func (s *Site) locker() (wtfs.Locker, error) {
+	l, ok := s.Wiki.(wtfs.Locker)
+	if !ok {
+		return nil, wterr.New(wterr.ErrUnsupported, "the wiki isn't being kept anywhere that uses lockfiles")
+	}
+	return l, nil
+}
+
+func (s *Site) AdminLocks(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Admin-Locks", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+		lk, err := s.locker()
+		if err != nil {
+			return err
+		}
+
+		l, err := lk.Locks()
+		if err != nil {
+			return err
+		}
+
+		buf := &strings.Builder{}
+		err = s.templates.ExecuteTemplate(buf, "adminlocks", l)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Locks"), nil)
+	})
+}
+
+func (s *Site) AdminLocksPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Admin-Locks-Post", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+		lk, err := s.locker()
+		if err != nil {
+			return err
+		}
+
+		switch r.PostFormValue("action") {
+		case "clear":
+			err = lk.ClearLock(r.PostFormValue("file"))
+		case "stale":
+			err = clearStale(lk)
+		default:
+			return wterr.Newf(wterr.ErrInvalidInput, "can't `%v` locks", r.PostFormValue("action"))
+		}
+		// it might have been let go of in the meantime
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		http.Redirect(w, r, "/admin/locks", http.StatusSeeOther)
+		return nil
+	})
+}
+
+func clearStale(lk wtfs.Locker) error {
+	l, err := lk.Locks()
+	if err != nil {
+		return err
+	}
+	for _, x := range l {
+		if !x.Stale {
+			continue
+		}
+		if err := lk.ClearLock(x.File); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtfs"
+)
+
+type moveTempl struct {
+	Path wikithing.Path
+
+	To       string
+	Summary  string
+	Redirect bool
+
+	Error string
+}
+
+func (s *Site) Move(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Move-Page", func() error {
+		p := pagePath(r)
+		if err := s.checkPerm(r, p, wikithing.PermEdit); err != nil {
+			return err
+		}
+		if !s.Links.Exists(p) {
+			return s.PageNotFound(w, r, p)
+		}
+
+		return s.showMove(w, r, moveTempl{
+			Path:     p,
+			To:       p.String(),
+			Redirect: true,
+		})
+	})
+}
+
+func (s *Site) MovePost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Move-Page-Post", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no page given to move")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermEdit); err != nil {
+			return err
+		}
+
+		mt := moveTempl{
+			Path:     p,
+			To:       strings.TrimSpace(r.PostFormValue("to")),
+			Summary:  strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("summary"))),
+			Redirect: r.PostFormValue("redirect") != "",
+		}
+		fail := func(msg string) error {
+			mt.Error = msg
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showMove(w, r, mt)
+		}
+
+		to := wikithing.ParsePath(mt.To)
+		switch {
+		case to.IsZero():
+			return fail("a page to move to is needed")
+		case to.Equal(p):
+			return fail("that's where the page already is")
+		case mt.Summary == "":
+			return fail("a reason is required")
+		}
+		if err := s.checkPerm(r, to, wikithing.PermEdit); err != nil {
+			return err
+		}
+
+		err := s.Wiki.MovePage(p, to, s.logEntry(r, mt.Summary), mt.Redirect)
+		if os.IsExist(err) {
+			return fail("there is already a page, or the history of one, at " + to.String())
+		}
+		if err == wtfs.ErrTalkExists {
+			return fail("there is already a discussion at " + to.String())
+		}
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, "/page/"+to.Path(), http.StatusSeeOther)
+		return nil
+	})
+}
+
+func (s *Site) showMove(w http.ResponseWriter, r *http.Request, mt moveTempl) error {
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, "move", mt)
+	if err != nil {
+		return err
+	}
+
+	return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Moving "+mt.Path.String()), nil)
+}
@@ -1,11 +1,144 @@
 package web
 
 import (
+	"html/template"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtfs"
+	"github.com/go-chi/chi"
 )
 
+// pagePath gets the wiki path from the tail of a request url, leaving off the page reference if there is one
+func pagePath(r *http.Request) wikithing.Path {
+	p, _ := wikithing.SplitPageRef(chi.URLParam(r, "*"))
+	return wikithing.ParsePath(p)
+}
+
+// pageRef gets which page of an article a request url is for, it's empty for the first one
+func pageRef(r *http.Request) string {
+	_, ref := wikithing.SplitPageRef(chi.URLParam(r, "*"))
+	return ref
+}
+
+// refSuffix is what goes on the end of a url to pick out a page of an article
+func refSuffix(a wikithing.Article, i int) string {
+	if i <= 0 {
+		return ""
+	}
+	return "/" + wikithing.PageRefPrefix + a.PageSlug(i)
+}
+
+// findPage finds the page a request is for in an article
+func findPage(r *http.Request, a wikithing.Article) (int, error) {
+	ref := pageRef(r)
+	if ref == "" {
+		return 0, nil
+	}
+	i, ok := a.FindPage(ref)
+	if !ok {
+		return 0, wterr.Newf(wterr.ErrInvalidInput, "this article has no page `%v`", ref)
+	}
+	return i, nil
+}
+
 func (s *Site) Page(w http.ResponseWriter, r *http.Request) {
 	s.WrapRun(w, r, "Serve-Page", func() error {
-		return s.ShowPage(w, r, "a", TitleHead("Page"), nil)
+		p := pagePath(r)
+		if p.IsZero() {
+			http.Redirect(w, r, "/page/index", http.StatusFound)
+			return nil
+		}
+		// keep urls consistent, so /page/Some.Page ends up at /page/some/page
+		if raw, ref := wikithing.SplitPageRef(chi.URLParam(r, "*")); raw != p.Path() {
+			u := "/page/" + p.Path()
+			if ref != "" {
+				u += "/" + wikithing.PageRefPrefix + ref
+			}
+			if r.URL.RawQuery != "" {
+				u += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, u, http.StatusMovedPermanently)
+			return nil
+		}
+		if err := s.checkPerm(r, p, wikithing.PermRead); err != nil {
+			return err
+		}
+
+		var a wikithing.Article
+		var err error
+		rev := r.URL.Query().Get("rev")
+		if rev == "" {
+			a, err = s.Wiki.LoadPage(p)
+		} else {
+			at, perr := parseRevision(rev)
+			if perr != nil {
+				return perr
+			}
+			a, err = s.Wiki.LoadPageRevision(p, at)
+		}
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+
+		var redirErr string
+		if _, ok := a.RedirectTarget(); ok && rev == "" && r.URL.Query().Get("redirect") != "no" {
+			chain, err := s.Wiki.FollowRedirects(p)
+			switch err {
+			case nil:
+				t := chain[len(chain)-1]
+				http.Redirect(w, r, "/page/"+t.Path()+"?redirectedfrom="+url.QueryEscape(p.String()), http.StatusFound)
+				return nil
+			case wtfs.ErrRedirectLoop, wtfs.ErrTooManyRedirects:
+				// show the redirect itself so it can be fixed
+				redirErr = err.Error()
+			default:
+				return err
+			}
+		}
+
+		sel, err := findPage(r, a)
+		if err != nil {
+			return err
+		}
+		// pages are always linked to by their slug, so ~2 goes to ~runbook and the first page has none at all
+		if ref := pageRef(r); ref != "" && (sel == 0 || ref != a.PageSlug(sel)) {
+			u := "/page/" + p.Path() + refSuffix(a, sel)
+			if r.URL.RawQuery != "" {
+				u += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, u, http.StatusFound)
+			return nil
+		}
+
+		content, err := s.renderArticle(r, p, rev, a, sel, redirErr)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowTabbedPage(w, r, content, TitleHead(articleTitle(p, a, sel)), s.pageSidebar(r, p), articleTabs(p, a, sel, rev))
 	})
 }
+
+type notFoundTempl struct {
+	Path wikithing.Path
+}
+
+// PageNotFound shows the page used for wiki paths that don't have anything in them yet
+func (s *Site) PageNotFound(w http.ResponseWriter, r *http.Request, p wikithing.Path) error {
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, "notfound", notFoundTempl{Path: p})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead(p.String()), nil)
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+)
+
+// maxArticlePages is the most pages an article can be split into
+const maxArticlePages = 20
+
+type pagesEntry struct {
+	Title string
+	Slug  string
+	Ref   string
+
+	First, Last bool
+}
+
+type pagesTempl struct {
+	Path  wikithing.Path
+	Pages []pagesEntry
+
+	// Title and Format are what was put in for adding a new page
+	Title   string
+	Format  string
+	Formats []string
+
+	Error string
+}
+
+// ArticlePages shows the pages an article is made up of, with controls for adding, removing and reordering them
+func (s *Site) ArticlePages(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Article-Pages", func() error {
+		p := pagePath(r)
+		if err := s.checkPerm(r, p, wikithing.PermEdit); err != nil {
+			return err
+		}
+
+		a, err := s.Wiki.LoadPage(p)
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+
+		return s.showPages(w, r, p, a, pagesTempl{Format: wikithing.FormatMarkdown})
+	})
+}
+
+func (s *Site) ArticlePagesPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Article-Pages-Post", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no article given")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermEdit); err != nil {
+			return err
+		}
+
+		base, err := s.Wiki.PageStamp(p)
+		if err != nil {
+			return err
+		}
+		a, err := s.Wiki.LoadPage(p)
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+
+		pt := pagesTempl{
+			Title:  strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("title"))),
+			Format: r.PostFormValue("format"),
+		}
+		fail := func(msg string) error {
+			pt.Error = msg
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showPages(w, r, p, a, pt)
+		}
+
+		action := r.PostFormValue("action")
+		if action == "add" {
+			switch {
+			case pt.Title == "":
+				return fail("new pages need a title")
+			case !validFormat(pt.Format):
+				return fail("unknown page format: " + pt.Format)
+			case len(a.Pages) >= maxArticlePages:
+				return fail("articles can't have more than " + strconv.Itoa(maxArticlePages) + " pages")
+			}
+			if _, ok := a.FindPage(pt.Title); ok {
+				return fail("there is already a page called " + pt.Title)
+			}
+
+			a.Pages = append(a.Pages, wikithing.Page{Title: pt.Title, Format: pt.Format})
+			err := s.Wiki.SavePage(p, a, base, s.logEntry(r, "added the page "+pt.Title))
+			if err != nil {
+				return err
+			}
+
+			http.Redirect(w, r, "/edit/"+p.Path()+refSuffix(a, len(a.Pages)-1), http.StatusSeeOther)
+			return nil
+		}
+
+		// everything else is done to an existing page, which is referred to by its slug
+		// so if someone else has reordered things in the meantime the right page still gets changed
+		i, ok := a.FindPage(r.PostFormValue("page"))
+		if !ok {
+			return fail("that page doesn't exist anymore")
+		}
+		name := a.Pages[i].Title
+		if name == "" {
+			name = "page " + strconv.Itoa(i+1)
+		}
+
+		var why string
+		switch action {
+		case "remove":
+			if len(a.Pages) == 1 {
+				return fail("the only page of an article can't be removed, delete the article instead")
+			}
+			a.Pages = append(a.Pages[:i], a.Pages[i+1:]...)
+			why = "removed the page " + name
+		case "up", "down":
+			j := i - 1
+			if action == "down" {
+				j = i + 1
+			}
+			if j < 0 || j >= len(a.Pages) {
+				return fail("that page can't be moved any further " + action)
+			}
+			a.Pages[i], a.Pages[j] = a.Pages[j], a.Pages[i]
+			why = "moved the page " + name + " " + action
+		default:
+			return wterr.Newf(wterr.ErrInvalidInput, "unknown action `%v`", action)
+		}
+
+		err = s.Wiki.SavePage(p, a, base, s.logEntry(r, why))
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, "/pages/"+p.Path(), http.StatusSeeOther)
+		return nil
+	})
+}
+
+func (s *Site) showPages(w http.ResponseWriter, r *http.Request, p wikithing.Path, a wikithing.Article, pt pagesTempl) error {
+	pt.Path = p
+	pt.Formats = wikithing.Formats
+	for i, x := range a.Pages {
+		pt.Pages = append(pt.Pages, pagesEntry{
+			Title: x.Title,
+			Slug:  a.PageSlug(i),
+			Ref:   refSuffix(a, i),
+			First: i == 0,
+			Last:  i == len(a.Pages)-1,
+		})
+	}
+
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, "pages", pt)
+	if err != nil {
+		return err
+	}
+
+	return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Pages of "+p.String()), nil)
+}
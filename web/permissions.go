@@ -0,0 +1,369 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+)
+
+// permCache keeps the permissions in memory since they're needed on every request
+type permCache struct {
+	m sync.RWMutex
+	p wikithing.Permissions
+}
+
+// Permissions gets the current permission rules
+func (s *Site) Permissions() wikithing.Permissions {
+	s.perms.m.RLock()
+	defer s.perms.m.RUnlock()
+	return s.perms.p
+}
+
+func (s *Site) loadPermissions() error {
+	ps, err := s.Wiki.LoadPermissions()
+	if err != nil {
+		return err
+	}
+
+	s.perms.m.Lock()
+	s.perms.p = ps
+	s.perms.m.Unlock()
+	return nil
+}
+
+// updatePermissions changes the permissions and saves them
+func (s *Site) updatePermissions(why wikithing.LogEntry, fn func(ps *wikithing.Permissions)) error {
+	s.perms.m.Lock()
+	defer s.perms.m.Unlock()
+
+	ps := s.perms.p
+	// the lists get copied so a failed save doesn't leave half a change behind
+	ps.Rules = append([]wikithing.Rule(nil), ps.Rules...)
+	ps.Protected = append([]string(nil), ps.Protected...)
+	fn(&ps)
+
+	err := s.Wiki.SavePermissions(ps, why)
+	if err != nil {
+		return err
+	}
+	s.perms.p = ps
+	return nil
+}
+
+// can checks if whoever made a request is allowed to do something to a page
+func (s *Site) can(r *http.Request, p wikithing.Path, perm wikithing.Permission) bool {
+	return s.Permissions().Allowed(s.CurrentUser(r), p, perm)
+}
+
+// checkPerm is can but giving an error that gets shown as a 403
+func (s *Site) checkPerm(r *http.Request, p wikithing.Path, perm wikithing.Permission) error {
+	u := s.CurrentUser(r)
+	if s.Permissions().Allowed(u, p, perm) {
+		return nil
+	}
+	if u == nil {
+		return wterr.Newf(wterr.ErrAuthFailed, "you need to log in to %v %v", perm, p)
+	}
+	return wterr.Newf(wterr.ErrAuthFailed, "you aren't allowed to %v %v", perm, p)
+}
+
+// checkAdmin checks that whoever made a request is an admin
+func (s *Site) checkAdmin(r *http.Request) error {
+	if s.CurrentUser(r).InGroup(wikithing.GroupAdmin) {
+		return nil
+	}
+	return wterr.New(wterr.ErrAuthFailed, "only admins can do that")
+}
+
+type protectTempl struct {
+	Path      wikithing.Path
+	Protected bool
+}
+
+func (s *Site) Protect(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Protect-Page", func() error {
+		p := pagePath(r)
+		if err := s.checkPerm(r, p, wikithing.PermAdmin); err != nil {
+			return err
+		}
+
+		buf := &strings.Builder{}
+		err := s.templates.ExecuteTemplate(buf, "protect", protectTempl{
+			Path:      p,
+			Protected: s.Permissions().IsProtected(p),
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Protecting "+p.String()), nil)
+	})
+}
+
+func (s *Site) ProtectPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Protect-Page-Post", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no page given to protect")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermAdmin); err != nil {
+			return err
+		}
+
+		protect := r.PostFormValue("protect") == "true"
+		summary := strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("summary")))
+
+		action, what := wikithing.LogActionUnprotect, "Unprotected "
+		if protect {
+			action, what = wikithing.LogActionProtect, "Protected "
+		}
+		if summary == "" {
+			summary = what + p.String()
+		}
+
+		err := s.updatePermissions(s.logEntry(r, summary), func(ps *wikithing.Permissions) {
+			ps.SetProtected(p, protect)
+		})
+		if err != nil {
+			return err
+		}
+
+		le := s.logEntry(r, summary)
+		le.Action = uint(action)
+		// pages can be protected before they exist, they just don't have a log to put it in yet
+		err = s.Wiki.LogPageAction(p, le)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		http.Redirect(w, r, "/page/"+p.Path(), http.StatusSeeOther)
+		return nil
+	})
+}
+
+type adminPermsTempl struct {
+	Rules     string
+	Protected []wikithing.Path
+	Error     string
+}
+
+func (s *Site) AdminPermissions(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Admin-Permissions", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+
+		ps := s.Permissions()
+		return s.showAdminPerms(w, r, adminPermsTempl{Rules: formatRules(ps.Rules)})
+	})
+}
+
+func (s *Site) AdminPermissionsPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Admin-Permissions-Post", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+
+		text := r.PostFormValue("rules")
+		rules, err := parseRules(text)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return s.showAdminPerms(w, r, adminPermsTempl{Rules: text, Error: err.(wterr.Err).Str})
+		}
+
+		err = s.updatePermissions(s.logEntry(r, "Changed the permission rules"), func(ps *wikithing.Permissions) {
+			ps.Rules = rules
+		})
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, "/admin/permissions", http.StatusSeeOther)
+		return nil
+	})
+}
+
+func (s *Site) showAdminPerms(w http.ResponseWriter, r *http.Request, at adminPermsTempl) error {
+	for _, x := range s.Permissions().Protected {
+		at.Protected = append(at.Protected, wikithing.ParsePath(x))
+	}
+
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, "adminpermissions", at)
+	if err != nil {
+		return err
+	}
+
+	return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Permissions"), nil)
+}
+
+// formatRules writes out rules in the form used by the permissions page, one rule per line like
+//
+//	internal.* read=staff,leads edit=leads
+func formatRules(l []wikithing.Rule) string {
+	buf := &strings.Builder{}
+	for _, x := range l {
+		buf.WriteString(x.Pattern)
+		if len(x.Read) > 0 {
+			buf.WriteString(" read=" + strings.Join(x.Read, ","))
+		}
+		if len(x.Edit) > 0 {
+			buf.WriteString(" edit=" + strings.Join(x.Edit, ","))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// parseRules reads rules written the way formatRules does, blank lines and lines starting with # are skipped
+func parseRules(s string) ([]wikithing.Rule, error) {
+	var out []wikithing.Rule
+
+	for n, x := range strings.Split(s, "\n") {
+		f := strings.Fields(x)
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+
+		// a pattern of just * covers the whole wiki
+		wild := strings.HasSuffix(f[0], "*")
+		pre := strings.TrimSuffix(f[0], "*")
+		if strings.Contains(pre, "*") || (!wild && wikithing.ParsePath(pre).IsZero()) {
+			return nil, wterr.Newf(wterr.ErrInvalidInput, "line %v: invalid pattern `%v`", n+1, f[0])
+		}
+		r := wikithing.Rule{Pattern: f[0]}
+
+		for _, opt := range f[1:] {
+			i := strings.IndexByte(opt, '=')
+			if i < 0 {
+				return nil, wterr.Newf(wterr.ErrInvalidInput, "line %v: expected read= or edit= but got `%v`", n+1, opt)
+			}
+
+			var groups []string
+			for _, g := range strings.Split(opt[i+1:], ",") {
+				if !wikithing.ValidGroup(g) {
+					return nil, wterr.Newf(wterr.ErrInvalidInput, "line %v: invalid group `%v`", n+1, g)
+				}
+				groups = append(groups, g)
+			}
+
+			switch opt[:i] {
+			case "read":
+				r.Read = append(r.Read, groups...)
+			case "edit":
+				r.Edit = append(r.Edit, groups...)
+			default:
+				return nil, wterr.Newf(wterr.ErrInvalidInput, "line %v: unknown permission `%v`", n+1, opt[:i])
+			}
+		}
+
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+type adminUsersTempl struct {
+	Users []adminUser
+}
+
+type adminUser struct {
+	wikithing.User
+	Groups string
+}
+
+func (s *Site) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Admin-Users", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+
+		l, err := s.Wiki.ListUsers()
+		if err != nil {
+			return err
+		}
+		sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+
+		at := adminUsersTempl{}
+		for _, x := range l {
+			at.Users = append(at.Users, adminUser{User: x, Groups: strings.Join(x.Groups, ", ")})
+		}
+
+		buf := &strings.Builder{}
+		err = s.templates.ExecuteTemplate(buf, "adminusers", at)
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Users"), nil)
+	})
+}
+
+func (s *Site) AdminUsersPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Admin-Users-Post", func() error {
+		if err := s.checkAdmin(r); err != nil {
+			return err
+		}
+
+		u, err := s.Wiki.LoadUser(r.PostFormValue("name"))
+		if os.IsNotExist(err) {
+			return wterr.Newf(wterr.ErrInvalidInput, "no user named `%v`", r.PostFormValue("name"))
+		}
+		if err != nil {
+			return err
+		}
+
+		var groups []string
+		for _, x := range strings.Split(r.PostFormValue("groups"), ",") {
+			x = strings.ToLower(strings.TrimSpace(x))
+			if x == "" || x == wikithing.GroupUsers {
+				continue
+			}
+			if !wikithing.ValidGroup(x) {
+				return wterr.Newf(wterr.ErrInvalidInput, "invalid group `%v`", x)
+			}
+			groups = append(groups, x)
+		}
+
+		// don't let the last admin lock everyone out
+		if u.InGroup(wikithing.GroupAdmin) && !contains(groups, wikithing.GroupAdmin) {
+			l, err := s.Wiki.ListUsers()
+			if err != nil {
+				return err
+			}
+			admins := 0
+			for _, x := range l {
+				if x.InGroup(wikithing.GroupAdmin) {
+					admins++
+				}
+			}
+			if admins <= 1 {
+				return wterr.New(wterr.ErrInvalidInput, "can't remove the last admin")
+			}
+		}
+
+		u.Groups = groups
+		err = s.Wiki.SaveUser(u, s.logEntry(r, "Changed groups to "+strings.Join(groups, ", ")))
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return nil
+	})
+}
+
+func contains(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
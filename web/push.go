@@ -2,18 +2,33 @@ package web
 
 import (
 	"html/template"
+	"log"
 	"net/http"
+	"strings"
+
+	"git.lan/wikithing"
 )
 
 type pushTempl struct {
 	Content template.HTML
 	Head    Head
 	Sidebar []Sidebar
+	Tabs    []Tab
+
+	// User is who is logged in, if anyone
+	User *wikithing.User
+	// Here is the current url, for coming back to after logging in
+	Here string
 
 	Config Config
 }
 
 func (s *Site) ShowPage(w http.ResponseWriter, r *http.Request, content template.HTML, head Head, sidebar []Sidebar) error {
+	return s.ShowTabbedPage(w, r, content, head, sidebar, nil)
+}
+
+// ShowTabbedPage is ShowPage with a strip of tabs above the content
+func (s *Site) ShowTabbedPage(w http.ResponseWriter, r *http.Request, content template.HTML, head Head, sidebar []Sidebar, tabs []Tab) error {
 	if sidebar == nil {
 		sidebar = []Sidebar{s.DefaultSidebar()}
 	}
@@ -26,6 +41,10 @@ func (s *Site) ShowPage(w http.ResponseWriter, r *http.Request, content template
 		Content: content,
 		Head:    head,
 		Sidebar: sidebar,
+		Tabs:    tabs,
+
+		User: s.CurrentUser(r),
+		Here: r.URL.RequestURI(),
 	})
 }
 
@@ -36,12 +55,26 @@ type Sidebar struct {
 }
 
 func (s *Site) DefaultSidebar() Sidebar {
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, "sidebar", nil)
+	if err != nil {
+		log.Println("!", err)
+	}
+
 	return Sidebar{
-		Title:   "TODO",
-		Content: "todo, make this do stuff",
+		Title:   "Wiki",
+		Content: template.HTML(buf.String()),
 	}
 }
 
+// Tab is a link to one of a set of related pages, like the pages of an article
+type Tab struct {
+	Label string
+	URL   string
+	// Current is set on the tab for the page being shown
+	Current bool
+}
+
 type Head struct {
 	Title string
 
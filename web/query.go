@@ -0,0 +1,118 @@
+package web
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+	"git.lan/wikithing/wtquery"
+)
+
+// runQuery parses and runs a query, only including pages the person making the request can read
+func (s *Site) runQuery(r *http.Request, q string) (wtquery.Result, error) {
+	pq, err := wtquery.Parse(q)
+	if err != nil {
+		return wtquery.Result{}, err
+	}
+
+	u := s.CurrentUser(r)
+	ps := s.Permissions()
+	return s.Tables.Run(pq, func(p wikithing.Path) bool {
+		return ps.Allowed(u, p, wikithing.PermRead)
+	}), nil
+}
+
+type queryTempl struct {
+	Query  string
+	Error  string
+	Result wtquery.Result
+}
+
+type queryJSON struct {
+	Query   string            `json:"query"`
+	Error   string            `json:"error,omitempty"`
+	Columns []string          `json:"columns"`
+	Results []queryJSONResult `json:"results"`
+	More    bool              `json:"more"`
+}
+
+type queryJSONResult struct {
+	Path   string              `json:"path"`
+	Title  string              `json:"title"`
+	Fields map[string][]string `json:"fields"`
+}
+
+// wantsJSON checks if a request asked for json instead of a page
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func (s *Site) QueryPage(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Query", func() error {
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+		if wantsJSON(r) {
+			return s.queryJSON(w, r, q)
+		}
+
+		qt := queryTempl{Query: q}
+		if q != "" {
+			res, err := s.runQuery(r, q)
+			if e, ok := err.(wterr.Err); ok {
+				w.WriteHeader(http.StatusBadRequest)
+				qt.Error = e.Str
+			} else if err != nil {
+				return err
+			}
+			qt.Result = res
+		}
+
+		buf := &strings.Builder{}
+		err := s.templates.ExecuteTemplate(buf, "query", qt)
+		if err != nil {
+			return err
+		}
+
+		title := "Query"
+		if q != "" {
+			title = "Query for " + q
+		}
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead(title), nil)
+	})
+}
+
+func (s *Site) queryJSON(w http.ResponseWriter, r *http.Request, q string) error {
+	out := queryJSON{Query: q, Columns: []string{}, Results: []queryJSONResult{}}
+	code := http.StatusOK
+
+	res, err := s.runQuery(r, q)
+	if e, ok := err.(wterr.Err); ok {
+		code = http.StatusBadRequest
+		out.Error = e.Str
+	} else if err != nil {
+		return err
+	}
+
+	if res.Columns != nil {
+		out.Columns = res.Columns
+	}
+	out.More = res.More
+	for _, x := range res.Rows {
+		jr := queryJSONResult{
+			Path:   x.Path.String(),
+			Title:  x.Title,
+			Fields: make(map[string][]string, len(x.Table.Fields)),
+		}
+		for _, f := range x.Table.Fields {
+			jr.Fields[f.Key] = f.Rows
+		}
+		out.Results = append(out.Results, jr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(out)
+}
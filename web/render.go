@@ -0,0 +1,130 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtquery"
+	"git.lan/wikithing/wtrender"
+)
+
+type renderedPage struct {
+	Title string
+	Body  template.HTML
+	// Infobox is the table to show next to the page, if it has one and doesn't put it in the body itself
+	Infobox *wikithing.Table
+}
+
+type articleTempl struct {
+	Path   wikithing.Path
+	Crumbs []crumb
+	// Revision is set when showing an old revision of a page
+	Revision string
+	Page     renderedPage
+	// Ref is what goes on the end of urls to the page to pick it out of the article
+	Ref string
+	// Multi is set if the article has more than one page
+	Multi bool
+
+	// RedirectedFrom is the page that redirected here
+	RedirectedFrom wikithing.Path
+	// Redirect is where the article redirects to, for when looking at an old revision of a redirect
+	Redirect wikithing.Path
+	// RedirectError is why the redirect wasn't followed, if it's broken
+	RedirectError string
+
+	CanEdit   bool
+	IsAdmin   bool
+	Protected bool
+}
+
+// renderArticle renders one of the pages of an article into a chunk of html to go in the base template
+func (s *Site) renderArticle(r *http.Request, p wikithing.Path, rev string, a wikithing.Article, sel int, redirErr string) (template.HTML, error) {
+	at := articleTempl{
+		Path:     p,
+		Crumbs:   s.breadcrumbs(p),
+		Revision: rev,
+		Ref:      refSuffix(a, sel),
+		Multi:    len(a.Pages) > 1,
+
+		CanEdit:   s.can(r, p, wikithing.PermEdit),
+		IsAdmin:   s.can(r, p, wikithing.PermAdmin),
+		Protected: s.Permissions().IsProtected(p),
+
+		RedirectedFrom: wikithing.ParsePath(r.URL.Query().Get("redirectedfrom")),
+		RedirectError:  redirErr,
+	}
+	at.Redirect, _ = a.RedirectTarget()
+
+	// queries in the page only show what the person looking at it can read
+	rr := *s.Renderer
+	rr.Query = func(q string) (wtquery.Result, error) { return s.runQuery(r, q) }
+
+	if sel < len(a.Pages) {
+		x := a.Pages[sel]
+		body, err := rr.Render(x)
+		if err != nil {
+			return "", err
+		}
+
+		at.Page = renderedPage{
+			Title: x.Title,
+			Body:  body,
+		}
+		if len(x.Table.Fields) > 0 && !wtrender.InlineInfobox(x) {
+			t := x.Table
+			at.Page.Infobox = &t
+		}
+	}
+
+	buf := &strings.Builder{}
+	err := s.templates.ExecuteTemplate(buf, "article", at)
+	return template.HTML(buf.String()), err
+}
+
+// articleTitle picks the title to show in the browser for a page of an article
+func articleTitle(p wikithing.Path, a wikithing.Article, sel int) string {
+	t := p.String()
+	if len(a.Pages) > 0 && a.Pages[0].Title != "" {
+		t = a.Pages[0].Title
+	}
+	if sel > 0 && sel < len(a.Pages) && a.Pages[sel].Title != "" {
+		t += " - " + a.Pages[sel].Title
+	}
+	return t
+}
+
+// talkTab is passed to articleTabs as the selected page when showing the discussion
+const talkTab = -1
+
+// articleTabs makes the tabs for switching between the pages of an article and its discussion
+func articleTabs(p wikithing.Path, a wikithing.Article, sel int, rev string) []Tab {
+	tabs := make([]Tab, 0, len(a.Pages)+1)
+	for i, x := range a.Pages {
+		t := Tab{
+			Label:   x.Title,
+			URL:     "/page/" + p.Path() + refSuffix(a, i),
+			Current: i == sel,
+		}
+		if t.Label == "" {
+			t.Label = "Page " + strconv.Itoa(i+1)
+		}
+		if rev != "" {
+			t.URL += "?rev=" + url.QueryEscape(rev)
+		}
+		tabs = append(tabs, t)
+	}
+	if len(tabs) == 0 {
+		tabs = append(tabs, Tab{Label: "Page", URL: "/page/" + p.Path()})
+	}
+
+	return append(tabs, Tab{
+		Label:   "Talk",
+		URL:     "/talk/" + p.Path(),
+		Current: sel == talkTab,
+	})
+}
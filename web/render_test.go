@@ -0,0 +1,33 @@
+package web_test
+
+import (
+	"strings"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/web"
+	"git.lan/wikithing/wtfs"
+)
+
+func TestMediaURL(t *testing.T) {
+	p := wikithing.Page{Body: "{{media:#abc | a cat}}", Format: wikithing.FormatFormatthing}
+
+	for url, want := range map[string]string{
+		"":                       `class="media-missing"`,
+		"http://localhost:5555/": `<img src="http://localhost:5555/abc"`,
+	} {
+		s := new(web.Site)
+		err := s.Initialise(web.Options{Store: wtfs.NewMemory(), MediaURL: url})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := s.Renderer.Render(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(h), want) {
+			t.Errorf("media with the media url %q came out as %v", url, h)
+		}
+	}
+}
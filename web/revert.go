@@ -0,0 +1,86 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+type revertTempl struct {
+	Path wikithing.Path
+	Rev  wtfs.Revision
+
+	Actor string
+}
+
+// revertTarget finds the revision a revert request is for
+func (s *Site) revertTarget(r *http.Request) (wikithing.Path, wtfs.Revision, error) {
+	p := pagePath(r)
+	if err := s.checkPerm(r, p, wikithing.PermAdmin); err != nil {
+		return p, wtfs.Revision{}, err
+	}
+
+	revs, err := s.Wiki.PageRevisions(p)
+	if err != nil {
+		return p, wtfs.Revision{}, err
+	}
+	i, err := findRevision(revs, r.URL.Query().Get("rev"))
+	if err != nil {
+		return p, wtfs.Revision{}, err
+	}
+
+	return p, revs[i], nil
+}
+
+func (s *Site) Revert(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Revert-Page", func() error {
+		p, rev, err := s.revertTarget(r)
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+
+		buf := &strings.Builder{}
+		err = s.templates.ExecuteTemplate(buf, "revert", revertTempl{
+			Path:  p,
+			Rev:   rev,
+			Actor: s.actorName(rev.LogEntry),
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead("Reverting "+p.String()), nil)
+	})
+}
+
+func (s *Site) RevertPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Revert-Page-Post", func() error {
+		p, rev, err := s.revertTarget(r)
+		if os.IsNotExist(err) {
+			return s.PageNotFound(w, r, p)
+		}
+		if err != nil {
+			return err
+		}
+
+		summary := strings.TrimSpace(wikithing.NormaliseText(r.PostFormValue("summary")))
+		if summary == "" {
+			summary = "Reverted to the revision from " + rev.Stamp
+		}
+
+		err = s.Wiki.RevertPage(p, rev.When, s.logEntry(r, summary))
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, "/page/"+p.Path(), http.StatusSeeOther)
+		return nil
+	})
+}
@@ -27,6 +27,44 @@ func (r *Site) SetupChi() error {
 	r.R.NotFound(theMostHorrible404)
 	r.R.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.FS(r.StaticFS))))
 	r.R.Get("/page/*", r.Page)
+	r.R.Get("/edit/*", r.Edit)
+	r.R.Post("/edit/*", r.EditPost)
+	r.R.Get("/history/*", r.History)
+	r.R.Get("/diff/*", r.Diff)
+	r.R.Get("/revert/*", r.Revert)
+	r.R.Post("/revert/*", r.RevertPost)
+	r.R.Get("/talk/*", r.Talk)
+	r.R.Post("/talk/*", r.TalkPost)
+	r.R.Get("/pages/*", r.ArticlePages)
+	r.R.Post("/pages/*", r.ArticlePagesPost)
+	r.R.Get("/move/*", r.Move)
+	r.R.Post("/move/*", r.MovePost)
+	r.R.Get("/delete/*", r.Delete)
+	r.R.Post("/delete/*", r.DeletePost)
+	r.R.Get("/delete-media/*", r.DeleteMedia)
+	r.R.Post("/delete-media/*", r.DeleteMediaPost)
+	r.R.Get("/protect/*", r.Protect)
+	r.R.Post("/protect/*", r.ProtectPost)
+	r.R.Get("/admin/permissions", r.AdminPermissions)
+	r.R.Post("/admin/permissions", r.AdminPermissionsPost)
+	r.R.Get("/admin/trash", r.AdminTrash)
+	r.R.Post("/admin/trash/restore", r.AdminRestorePost)
+	r.R.Get("/admin/locks", r.AdminLocks)
+	r.R.Post("/admin/locks", r.AdminLocksPost)
+	r.R.Get("/admin/users", r.AdminUsers)
+	r.R.Post("/admin/users", r.AdminUsersPost)
+	r.R.Get("/search", r.SearchPage)
+	r.R.Get("/query", r.QueryPage)
+	r.R.Get("/browse", r.Browse)
+	r.R.Get("/browse/*", r.Browse)
+	r.R.Get("/backlinks/*", r.Backlinks)
+	r.R.Get("/wanted", r.WantedPages)
+	r.R.Get("/orphans", r.OrphanedPages)
+	r.R.Get("/login", r.Login)
+	r.R.Post("/login", r.LoginPost)
+	r.R.Post("/logout", r.LogoutPost)
+	r.R.Get("/register", r.Register)
+	r.R.Post("/register", r.RegisterPost)
 	r.R.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(301)
 		fmt.Fprint(w, `<!DOCTYPE html><html><head><meta http-equiv="Refresh" content="0; url='/page/index" /></head></html>`)
@@ -70,12 +108,48 @@ var theMostHorrible404 = func(w http.ResponseWriter, r *http.Request) {
 func (s *Site) HandleWterr(w http.ResponseWriter, r *http.Request, e wterr.Err) {
 	// TODO: kinda important that this needs to be able to categorise more errors lol
 	switch e.Type {
+	case wterr.ErrAuthFailed:
+		s.HandleErrorCode(w, r, http.StatusForbidden, e)
+	case wterr.ErrInvalidInput:
+		s.HandleErrorCode(w, r, http.StatusBadRequest, e)
+	case wterr.ErrUnsupported:
+		s.HandleErrorCode(w, r, http.StatusNotImplemented, e)
+	case wterr.ErrConflict:
+		s.HandleErrorCode(w, r, http.StatusConflict, e)
 
 	default:
 		s.HandleGenericError(w, r, e)
 	}
 }
 
+type errorCodeTempl struct {
+	ErrorCode int
+	ErrorName string
+	Message   string
+}
+
+// HandleErrorCode shows an error that is safe to show the user along with a status code
+func (s *Site) HandleErrorCode(w http.ResponseWriter, r *http.Request, code int, e wterr.Err) {
+	w.WriteHeader(code)
+	buf := &strings.Builder{}
+
+	err := s.templates.ExecuteTemplate(buf, "error", errorCodeTempl{
+		ErrorCode: code,
+		ErrorName: http.StatusText(code),
+		Message:   e.Str,
+	})
+	if err != nil {
+		log.Println("!", err)
+		return
+	}
+
+	err = s.ShowPage(w, r, template.HTML(buf.String()), Head{Title: "Error"}, nil)
+	if err != nil {
+		log.Println("!", err)
+		return
+	}
+}
+
 type ErrorContext struct {
 	ErrorCode int
 
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtlinks"
+	"git.lan/wikithing/wtquery"
+	"git.lan/wikithing/wtrender"
+	"git.lan/wikithing/wtsearch"
+)
+
+// maxSearchResults is how many results are shown for a search
+const maxSearchResults = 50
+
+// buildIndexes sets up the search index, link graph and table index from every page and keeps them up to date as pages change
+func (s *Site) buildIndexes() error {
+	s.Search = wtsearch.New()
+	s.Links = wtlinks.New()
+	s.Tables = wtquery.New()
+
+	index := func(loc wikithing.Path, a wikithing.Article) {
+		// redirects don't have anything worth finding in them
+		if _, ok := a.RedirectTarget(); ok {
+			s.Search.Remove(loc)
+			s.Tables.Remove(loc)
+		} else {
+			s.Search.Add(loc, a)
+			s.Tables.Set(loc, a)
+		}
+		s.Links.Set(loc, wtrender.ArticleLinks(a))
+	}
+	// hooks for changes made close together can run in either order, so rather than trusting what they're given
+	// the page is loaded again, one at a time, so the indexes always end up with whatever was saved last
+	var mu sync.Mutex
+	refresh := func(loc wikithing.Path) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		a, err := s.Wiki.LoadPage(loc)
+		switch {
+		case os.IsNotExist(err):
+			s.Search.Remove(loc)
+			s.Links.Remove(loc)
+			s.Tables.Remove(loc)
+		case err != nil:
+			log.Println("Indexing", loc, "failed:", err)
+		default:
+			index(loc, a)
+		}
+	}
+	s.Wiki.OnPageChange(func(loc wikithing.Path, _ wikithing.Article) { refresh(loc) })
+	s.Wiki.OnPageRemove(refresh)
+
+	start := time.Now()
+	err := s.Wiki.WalkPages(func(loc wikithing.Path, a wikithing.Article) error {
+		index(loc, a)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("indexed %v pages in %v", s.Search.Len(), time.Since(start))
+	return nil
+}
+
+type searchTempl struct {
+	Query   string
+	Results []wtsearch.Result
+	// More is set if there were more results than are shown
+	More bool
+}
+
+func (s *Site) SearchPage(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Search", func() error {
+		st := searchTempl{Query: strings.TrimSpace(r.URL.Query().Get("q"))}
+
+		u := s.CurrentUser(r)
+		ps := s.Permissions()
+		for _, x := range s.Search.Search(wtsearch.ParseQuery(st.Query)) {
+			if !ps.Allowed(u, x.Path, wikithing.PermRead) {
+				continue
+			}
+			if len(st.Results) == maxSearchResults {
+				st.More = true
+				break
+			}
+			st.Results = append(st.Results, x)
+		}
+
+		buf := &strings.Builder{}
+		err := s.templates.ExecuteTemplate(buf, "search", st)
+		if err != nil {
+			return err
+		}
+
+		title := "Search"
+		if st.Query != "" {
+			title = "Search for " + st.Query
+		}
+		return s.ShowPage(w, r, template.HTML(buf.String()), TitleHead(title), nil)
+	})
+}
@@ -0,0 +1,52 @@
+package web_test
+
+import (
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/web"
+	"git.lan/wikithing/wtfs"
+	"git.lan/wikithing/wtsearch"
+)
+
+// the hooks for two saves can run in the opposite order to the saves, which shouldn't leave the older version indexed
+func TestIndexHooksOutOfOrder(t *testing.T) {
+	store := wtfs.NewMemory()
+	s := new(web.Site)
+	err := s.Initialise(web.Options{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc := wikithing.ParsePath("fruit")
+	older := wikithing.Article{Pages: []wikithing.Page{{Title: "Fruit", Body: "apple", Format: wikithing.FormatMarkdown}}}
+	newer := wikithing.Article{Pages: []wikithing.Page{{Title: "Fruit", Body: "banana", Format: wikithing.FormatMarkdown}}}
+
+	err = store.SavePage(loc, older, "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.SavePage(loc, newer, stamp(t, store, "fruit"), wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first save's hook running late
+	store.PageChanged(loc, older)
+
+	found := func(q string) bool {
+		return len(s.Search.Search(wtsearch.ParseQuery(q))) > 0
+	}
+	if found("apple") {
+		t.Error("the older version of the page is in the search index")
+	}
+	if !found("banana") {
+		t.Error("the newer version of the page isn't in the search index")
+	}
+
+	// as is a page being removed before being saved again
+	store.PageRemoved(loc)
+	if !found("banana") {
+		t.Error("the page was taken out of the search index while it's still there")
+	}
+}
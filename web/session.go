@@ -0,0 +1,143 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+)
+
+const (
+	sessionCookie = "wikithing-session"
+	sessionLength = time.Hour * 24 * 30
+)
+
+type session struct {
+	User    sid.ID
+	Name    string
+	Expires time.Time
+}
+
+// sessionStore keeps track of who is logged in, it only lives in memory so restarting logs everyone out
+type sessionStore struct {
+	m    sync.Mutex
+	list map[string]session
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{list: make(map[string]session)}
+}
+
+func (s *sessionStore) get(token string) (session, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	x, ok := s.list[token]
+	if !ok {
+		return x, false
+	}
+	if time.Now().After(x.Expires) {
+		delete(s.list, token)
+		return x, false
+	}
+	return x, true
+}
+
+func (s *sessionStore) add(u wikithing.User) (string, session, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", session{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	x := session{
+		User:    u.ID,
+		Name:    u.Name,
+		Expires: time.Now().Add(sessionLength),
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// a good a time as any to clear out old sessions
+	for k, v := range s.list {
+		if time.Now().After(v.Expires) {
+			delete(s.list, k)
+		}
+	}
+	s.list[token] = x
+
+	return token, x, nil
+}
+
+func (s *sessionStore) remove(token string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	delete(s.list, token)
+}
+
+// startSession logs someone in and gives them the cookie for it
+func (s *Site) startSession(w http.ResponseWriter, u wikithing.User) error {
+	token, x, err := s.sessions.add(u)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  x.Expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// endSession logs someone out
+func (s *Site) endSession(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		s.sessions.remove(c.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// CurrentUser gets the user that made a request, or nil if they aren't logged in
+func (s *Site) CurrentUser(r *http.Request) *wikithing.User {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	x, ok := s.sessions.get(c.Value)
+	if !ok {
+		return nil
+	}
+
+	u, err := s.Wiki.LoadUser(x.Name)
+	if err != nil || u.ID != x.User {
+		return nil
+	}
+	return &u
+}
+
+// logEntry makes a log entry for something done by whoever made a request
+func (s *Site) logEntry(r *http.Request, reason string) wikithing.LogEntry {
+	le := wikithing.LogEntry{Reason: reason}
+	if u := s.CurrentUser(r); u != nil {
+		le.Actor = u.ID
+	}
+	return le
+}
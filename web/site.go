@@ -2,12 +2,19 @@ package web
 
 import (
 	"embed"
+	"errors"
 	"html/template"
 	"io/fs"
 	"net/http"
 	"os"
 
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
 	"git.lan/wikithing/wtfs"
+	"git.lan/wikithing/wtlinks"
+	"git.lan/wikithing/wtquery"
+	"git.lan/wikithing/wtrender"
+	"git.lan/wikithing/wtsearch"
 	"github.com/go-chi/chi"
 )
 
@@ -21,6 +28,11 @@ type Options struct {
 	TemplateDir string
 	StaticDir   string
 	DataDir     string
+	// Store is used instead of the data in DataDir if it's set, like wtfs.NewMemory for tests
+	Store wtfs.Store
+
+	// MediaURL is where the media server can be found, without it media embedded in pages is shown as unavailable
+	MediaURL string
 
 	RevealRawErr bool
 }
@@ -28,10 +40,17 @@ type Options struct {
 type Site struct {
 	Opts Options
 
-	Wiki *wtfs.Filesystem
-	R    *chi.Mux
+	Wiki     wtfs.Store
+	Renderer *wtrender.Renderer
+	Search   *wtsearch.Index
+	Links    *wtlinks.Graph
+	Tables   *wtquery.Index
+	R        *chi.Mux
 
 	templates *template.Template
+	sessions  *sessionStore
+	names     userNames
+	perms     permCache
 
 	TemplFS  fs.FS
 	StaticFS fs.FS
@@ -64,14 +83,38 @@ func (r *Site) Initialise(opts Options) error {
 		return err
 	}
 
-	if opts.StaticDir == "" {
-		opts.StaticDir = "./data/"
+	if opts.Store != nil {
+		r.Wiki = opts.Store
+	} else {
+		if opts.DataDir == "" {
+			opts.DataDir = "./data/"
+		}
+		r.Wiki, err = wtfs.New(opts.DataDir)
+		if err != nil {
+			return err
+		}
 	}
-	r.Wiki, err = wtfs.New(opts.StaticDir)
+
+	r.sessions = newSessionStore()
+	r.names.names = make(map[sid.ID]string)
+	err = r.loadPermissions()
 	if err != nil {
 		return err
 	}
 
+	err = r.buildIndexes()
+	if err != nil {
+		return err
+	}
+
+	r.Renderer = &wtrender.Renderer{
+		MediaURL: opts.MediaURL,
+		Media: func(id sid.ID) (wikithing.FileObject, error) {
+			_, o, err := r.Wiki.MediaByID(id)
+			return o, err
+		},
+	}
+
 	return r.SetupChi()
 }
 
@@ -91,4 +134,20 @@ func (r *Site) loadTemplates() error {
 
 var templFuncs = template.FuncMap{
 	"uwu": func(a string) string { return "uwuth doth be doth" },
+
+	// dict makes a map out of key value pairs, for passing more than one thing to a template
+	"dict": func(kv ...interface{}) (map[string]interface{}, error) {
+		if len(kv)%2 != 0 {
+			return nil, errors.New("dict needs an even number of arguments")
+		}
+		m := make(map[string]interface{}, len(kv)/2)
+		for i := 0; i < len(kv); i += 2 {
+			k, ok := kv[i].(string)
+			if !ok {
+				return nil, errors.New("dict keys have to be strings")
+			}
+			m[k] = kv[i+1]
+		}
+		return m, nil
+	},
 }
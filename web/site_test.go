@@ -0,0 +1,194 @@
+package web_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/web"
+	"git.lan/wikithing/wtfs"
+)
+
+// newSite starts a site over an empty wiki in memory
+func newSite(t *testing.T) (*httptest.Server, wtfs.Store) {
+	t.Helper()
+	store := wtfs.NewMemory()
+
+	s := new(web.Site)
+	err := s.Initialise(web.Options{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(s.R)
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+// client doesn't follow redirects so the responses to posts can be checked
+var client = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+func get(t *testing.T, srv *httptest.Server, path string) (int, string) {
+	t.Helper()
+	res, err := client.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, string(b)
+}
+
+func edit(t *testing.T, srv *httptest.Server, path, body, base string) int {
+	t.Helper()
+	code, err := postEdit(srv, path, body, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func postEdit(srv *httptest.Server, path, body, base string) (int, error) {
+	res, err := client.PostForm(srv.URL+"/edit/"+path, url.Values{
+		"title":   {"Test"},
+		"body":    {body},
+		"format":  {wikithing.FormatMarkdown},
+		"summary": {"testing"},
+		"base":    {base},
+	})
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	return res.StatusCode, nil
+}
+
+func stamp(t *testing.T, store wtfs.Store, path string) string {
+	t.Helper()
+	s, err := store.PageStamp(wikithing.ParsePath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestViewPage(t *testing.T) {
+	srv, store := newSite(t)
+	err := store.SavePage(wikithing.ParsePath("some/page"), wikithing.Article{Pages: []wikithing.Page{{
+		Title:  "Some Page",
+		Body:   "hello **there**",
+		Format: wikithing.FormatMarkdown,
+	}}}, "", wikithing.LogEntry{Reason: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := get(t, srv, "/page/some/page")
+	if code != http.StatusOK {
+		t.Fatalf("got %v viewing the page", code)
+	}
+	if !strings.Contains(body, "<strong>there</strong>") {
+		t.Errorf("the page body wasn't rendered:\n%v", body)
+	}
+}
+
+func TestMissingPage(t *testing.T) {
+	srv, _ := newSite(t)
+	code, _ := get(t, srv, "/page/nothing/here")
+	if code != http.StatusNotFound {
+		t.Errorf("got %v viewing a page that doesn't exist", code)
+	}
+}
+
+func TestEditPage(t *testing.T) {
+	srv, store := newSite(t)
+
+	if code := edit(t, srv, "new/page", "first", ""); code != http.StatusSeeOther {
+		t.Fatalf("got %v creating a page", code)
+	}
+	if code := edit(t, srv, "new/page", "second", stamp(t, store, "new/page")); code != http.StatusSeeOther {
+		t.Fatalf("got %v editing a page", code)
+	}
+
+	a, err := store.LoadPage(wikithing.ParsePath("new/page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(a.Pages[0].Body) != "second" {
+		t.Errorf("the page is %q after editing it", a.Pages[0].Body)
+	}
+	revs, err := store.PageRevisions(wikithing.ParsePath("new/page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 {
+		t.Errorf("there are %v revisions, not 2", len(revs))
+	}
+}
+
+func TestEditConflict(t *testing.T) {
+	srv, store := newSite(t)
+
+	edit(t, srv, "page", "first", "")
+	base := stamp(t, store, "page")
+	edit(t, srv, "page", "theirs", base)
+
+	if code := edit(t, srv, "page", "mine", base); code != http.StatusConflict {
+		t.Fatalf("got %v saving over someone else's edit", code)
+	}
+	a, err := store.LoadPage(wikithing.ParsePath("page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(a.Pages[0].Body) != "theirs" {
+		t.Errorf("the page is %q after a conflicting save", a.Pages[0].Body)
+	}
+}
+
+func TestConcurrentEdits(t *testing.T) {
+	srv, store := newSite(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := fmt.Sprint("page/", i)
+			if _, err := postEdit(srv, p, "first", ""); err != nil {
+				t.Error(err)
+				return
+			}
+			base, err := store.PageStamp(wikithing.ParsePath(p))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			code, err := postEdit(srv, p, "second", base)
+			if err != nil || code != http.StatusSeeOther {
+				t.Errorf("got %v %v editing %v", code, err, p)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		a, err := store.LoadPage(wikithing.ParsePath(fmt.Sprint("page/", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(a.Pages[0].Body) != "second" {
+			t.Errorf("page %v is %q", i, a.Pages[0].Body)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/wterr"
+)
+
+// talkActivity is how many of the latest log entries are shown under a discussion
+const talkActivity = 15
+
+type talkComment struct {
+	wikithing.Comment
+	AuthorName string
+	Rendered   template.HTML
+
+	// CanEdit is set on comments the person looking at them wrote, CanDelete also for admins
+	CanEdit, CanDelete bool
+	Replies            []talkComment
+}
+
+type talkTempl struct {
+	Path   wikithing.Path
+	Crumbs []crumb
+
+	Threads  []talkComment
+	Activity []historyEntry
+
+	// CanPost is whether the person looking can comment at all
+	CanPost bool
+	// NoPage is set when there's no page to discuss, which nobody can comment on
+	NoPage bool
+	// Reply and Edit are the comment that has a reply or edit form open under it
+	Reply, Edit sid.ID
+	// Body and ThreadBody are what was in the reply or edit form and the new thread form, for when one has to be shown again
+	Body, ThreadBody string
+	Error            string
+}
+
+func (s *Site) Talk(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Talk-Page", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no page given")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermRead); err != nil {
+			return err
+		}
+
+		tt := talkTempl{}
+		tt.Reply, _ = sid.Parse(r.URL.Query().Get("reply"))
+		tt.Edit, _ = sid.Parse(r.URL.Query().Get("edit"))
+		return s.showTalk(w, r, p, tt)
+	})
+}
+
+func (s *Site) TalkPost(w http.ResponseWriter, r *http.Request) {
+	s.WrapRun(w, r, "Talk-Page-Post", func() error {
+		p := pagePath(r)
+		if p.IsZero() {
+			return wterr.New(wterr.ErrInvalidInput, "no page given")
+		}
+		if err := s.checkPerm(r, p, wikithing.PermRead); err != nil {
+			return err
+		}
+		u := s.CurrentUser(r)
+		if u == nil {
+			return wterr.New(wterr.ErrAuthFailed, "you need to log in to comment")
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxEditSize)
+		if err := r.ParseForm(); err != nil {
+			return wterr.New(wterr.ErrInvalidInput, err)
+		}
+
+		action := r.PostFormValue("action")
+		id, _ := sid.Parse(r.PostFormValue("id"))
+		body := normaliseBody(r.PostFormValue("body"))
+
+		tt := talkTempl{}
+		fail := func(err error) error {
+			e, ok := err.(wterr.Err)
+			if !ok {
+				return err
+			}
+			tt.Error = e.Str
+			switch {
+			case action == "edit":
+				tt.Edit, tt.Body = id, body
+			case id.IsZero():
+				tt.ThreadBody = body
+			default:
+				tt.Reply, tt.Body = id, body
+			}
+			if e.Type == wterr.ErrAuthFailed {
+				w.WriteHeader(http.StatusForbidden)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			return s.showTalk(w, r, p, tt)
+		}
+
+		// anchor is the comment to show once done
+		anchor := id
+		var why string
+		var change func(d *wikithing.Discussion) error
+		switch action {
+		case "post":
+			// discussions only go with pages, otherwise anyone could start them anywhere
+			if _, err := s.Wiki.LoadPage(p); os.IsNotExist(err) {
+				return fail(wterr.New(wterr.ErrInvalidInput, "there's no page here to discuss"))
+			} else if err != nil {
+				return err
+			}
+			if err := wikithing.ValidComment(body); err != nil {
+				return fail(err)
+			}
+			c := wikithing.Comment{ID: sid.Get(), Parent: id, Author: u.ID, Body: body}
+			why = "new comment"
+			if !id.IsZero() {
+				why = "reply to a comment"
+			}
+			change = func(d *wikithing.Discussion) error {
+				if !c.Parent.IsZero() {
+					i, ok := d.Find(c.Parent)
+					if !ok || d.Comments[i].Deleted {
+						return wterr.New(wterr.ErrInvalidInput, "the comment being replied to doesn't exist anymore")
+					}
+				}
+				d.Comments = append(d.Comments, c)
+				return nil
+			}
+			anchor = c.ID
+
+		case "edit":
+			if err := wikithing.ValidComment(body); err != nil {
+				return fail(err)
+			}
+			why = "edited a comment"
+			change = func(d *wikithing.Discussion) error {
+				c, err := ownComment(d, id, u, false)
+				if err != nil {
+					return err
+				}
+				c.Body, c.Edited = body, time.Now().UTC()
+				return nil
+			}
+
+		case "delete":
+			why = "deleted a comment"
+			change = func(d *wikithing.Discussion) error {
+				c, err := ownComment(d, id, u, true)
+				if err != nil {
+					return err
+				}
+				c.Body, c.Deleted = "", true
+				return nil
+			}
+
+		default:
+			return wterr.Newf(wterr.ErrInvalidInput, "unknown action `%v`", action)
+		}
+
+		// editing and deleting only make sense for discussions that are already there
+		if action != "post" {
+			if _, err := s.Wiki.LoadTalk(p); os.IsNotExist(err) {
+				return fail(wterr.New(wterr.ErrInvalidInput, "there's no discussion here yet"))
+			}
+		}
+
+		le := s.logEntry(r, why)
+		le.Ref = anchor.String()
+		err := s.Wiki.UpdateTalk(p, le, change)
+		if err != nil {
+			return fail(err)
+		}
+
+		http.Redirect(w, r, "/talk/"+p.Path()+"#c"+anchor.String(), http.StatusSeeOther)
+		return nil
+	})
+}
+
+// ownComment finds a comment that a user is allowed to change, admins can also delete anyone's comments
+func ownComment(d *wikithing.Discussion, id sid.ID, u *wikithing.User, deleting bool) (*wikithing.Comment, error) {
+	i, ok := d.Find(id)
+	if !ok || d.Comments[i].Deleted {
+		return nil, wterr.New(wterr.ErrInvalidInput, "that comment doesn't exist")
+	}
+	c := &d.Comments[i]
+	if c.Author != u.ID && !(deleting && u.InGroup(wikithing.GroupAdmin)) {
+		return nil, wterr.New(wterr.ErrAuthFailed, "you can only change your own comments")
+	}
+	return c, nil
+}
+
+func (s *Site) showTalk(w http.ResponseWriter, r *http.Request, p wikithing.Path, tt talkTempl) error {
+	tt.Path = p
+	tt.Crumbs = s.breadcrumbs(p)
+	u := s.CurrentUser(r)
+
+	a, err := s.Wiki.LoadPage(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	tt.NoPage = os.IsNotExist(err)
+	tt.CanPost = u != nil && !tt.NoPage
+
+	d, err := s.Wiki.LoadTalk(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	tt.Threads, err = s.talkComments(d.Threads(), u)
+	if err != nil {
+		return err
+	}
+
+	if l, err := s.Wiki.TalkLog(p); err == nil {
+		for i := len(l.Entries) - 1; i >= 0 && len(tt.Activity) < talkActivity; i-- {
+			x := l.Entries[i]
+			tt.Activity = append(tt.Activity, historyEntry{
+				LogEntry:  x,
+				ActorName: s.actorName(x),
+			})
+		}
+	}
+
+	buf := &strings.Builder{}
+	err = s.templates.ExecuteTemplate(buf, "talk", tt)
+	if err != nil {
+		return err
+	}
+
+	return s.ShowTabbedPage(w, r, template.HTML(buf.String()), TitleHead("Talk about "+articleTitle(p, a, 0)), s.pageSidebar(r, p), articleTabs(p, a, talkTab, ""))
+}
+
+func (s *Site) talkComments(l []wikithing.Thread, u *wikithing.User) ([]talkComment, error) {
+	out := make([]talkComment, 0, len(l))
+	for _, x := range l {
+		tc := talkComment{
+			Comment:    x.Comment,
+			AuthorName: s.userName(x.Author),
+		}
+		if !x.Deleted {
+			body, err := s.Renderer.Markdown(x.Body)
+			if err != nil {
+				return nil, err
+			}
+			tc.Rendered = body
+			tc.CanEdit = u != nil && u.ID == x.Author
+			tc.CanDelete = tc.CanEdit || u.InGroup(wikithing.GroupAdmin)
+		}
+
+		var err error
+		tc.Replies, err = s.talkComments(x.Replies, u)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, tc)
+	}
+	return out, nil
+}
@@ -0,0 +1,59 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"testing"
+
+	"git.lan/wikithing"
+)
+
+func TestTalkNeedsPage(t *testing.T) {
+	srv, store := newSite(t)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &http.Client{Jar: jar, CheckRedirect: client.CheckRedirect}
+	res, err := c.PostForm(srv.URL+"/register", url.Values{
+		"name":     {"talker"},
+		"password": {"password1"},
+		"confirm":  {"password1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	post := func(path string) int {
+		t.Helper()
+		res, err := c.PostForm(srv.URL+"/talk/"+path, url.Values{
+			"action": {"post"},
+			"body":   {"what about this"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if code := post("nowhere"); code != http.StatusBadRequest {
+		t.Errorf("got %v commenting where there's no page", code)
+	}
+	if _, err := store.LoadTalk(wikithing.ParsePath("nowhere")); !os.IsNotExist(err) {
+		t.Errorf("a discussion was started where there's no page (%v)", err)
+	}
+
+	edit(t, srv, "somewhere", "page", "")
+	if code := post("somewhere"); code != http.StatusSeeOther {
+		t.Errorf("got %v commenting on a page", code)
+	}
+	d, err := store.LoadTalk(wikithing.ParsePath("somewhere"))
+	if err != nil || len(d.Comments) != 1 {
+		t.Errorf("the comment wasn't saved: %v %v", d, err)
+	}
+}
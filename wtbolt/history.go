@@ -0,0 +1,104 @@
+package wtbolt
+
+import (
+	"os"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (db *DB) PageLog(loc wikithing.Path) (wikithing.LogFile, error) {
+	return db.loadLog(wtfs.Pages, loc)
+}
+
+func (db *DB) PageRevisions(loc wikithing.Path) (revs []wtfs.Revision, err error) {
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		revs, err = revisions(entry(tx, wtfs.Pages, loc))
+		return err
+	})
+	return revs, err
+}
+
+func (db *DB) LoadPageRevision(loc wikithing.Path, at time.Time) (a wikithing.Article, err error) {
+	return a, db.DB.View(func(tx *bolt.Tx) error {
+		return loadRevision(entry(tx, wtfs.Pages, loc), at, &a)
+	})
+}
+
+// RevertPage restores an old revision of a page as a new revision
+func (db *DB) RevertPage(loc wikithing.Path, at time.Time, why wikithing.LogEntry) error {
+	var a wikithing.Article
+	err := db.DB.Update(func(tx *bolt.Tx) error {
+		err := loadRevision(entry(tx, wtfs.Pages, loc), at, &a)
+		if err != nil {
+			return err
+		}
+		why.Ref = at.UTC().Format(wtfs.TimeFormat)
+		return update(tx, wtfs.Pages, loc, wikithing.LogActionRevert, why, a)
+	})
+	if err != nil {
+		return err
+	}
+	db.PageChanged(loc, a)
+	return nil
+}
+
+// LogPageAction adds an entry to the log of a page without changing the page
+func (db *DB) LogPageAction(loc wikithing.Path, why wikithing.LogEntry) error {
+	return db.DB.Update(func(tx *bolt.Tx) error {
+		b := entry(tx, wtfs.Pages, loc)
+		if b == nil || b.Get([]byte(keyLog)) == nil {
+			return os.ErrNotExist
+		}
+		why.When = time.Now().UTC()
+		return appendLog(b, why)
+	})
+}
+
+func (db *DB) loadLog(pre string, p wikithing.Path) (l wikithing.LogFile, err error) {
+	return l, db.DB.View(func(tx *bolt.Tx) error {
+		l, err = loadLog(entry(tx, pre, p))
+		return err
+	})
+}
+
+func revisions(b *bolt.Bucket) ([]wtfs.Revision, error) {
+	l, err := loadLog(b)
+	if err != nil {
+		return nil, err
+	}
+
+	revs := make([]wtfs.Revision, 0, len(l.Entries))
+	for _, x := range l.Entries {
+		if !wtfs.ChangesContent(x.Action) {
+			continue
+		}
+		revs = append(revs, wtfs.Revision{
+			LogEntry: x,
+			Stamp:    x.When.Format(wtfs.TimeFormat),
+		})
+	}
+
+	if len(revs) > 0 && b.Get([]byte(keyCurrent)) != nil {
+		revs[len(revs)-1].Current = true
+	}
+	return revs, nil
+}
+
+// loadRevision loads a revision, unlike wtfs every revision is kept under its own stamp
+func loadRevision(b *bolt.Bucket, at time.Time, dat interface{}) error {
+	revs, err := revisions(b)
+	if err != nil {
+		return err
+	}
+
+	stamp := at.UTC().Format(wtfs.TimeFormat)
+	for _, x := range revs {
+		if x.Stamp == stamp {
+			return getJSON(b, keyRevision+stamp, dat)
+		}
+	}
+	return os.ErrNotExist
+}
@@ -0,0 +1,67 @@
+package wtbolt
+
+import (
+	"os"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (db *DB) LoadMedia(loc wikithing.Path) (a wikithing.FileObject, err error) {
+	return a, db.load(wtfs.Media, loc, &a)
+}
+
+func (db *DB) SaveMedia(loc wikithing.Path, media wikithing.FileObject, why wikithing.LogEntry) error {
+	return db.DB.Update(func(tx *bolt.Tx) error {
+		err := update(tx, wtfs.Media, loc, wikithing.LogActionUnspecified, why, media)
+		if err != nil {
+			return err
+		}
+		return indexMedia(tx, media.ID, loc)
+	})
+}
+
+// bucketMediaIDs has the path of media by its ID, so embedding it doesn't mean going through all of it.
+// Entries are checked when they're used so ones for media that's gone or changed since don't matter
+const bucketMediaIDs = "mediaids"
+
+func indexMedia(tx *bolt.Tx, id sid.ID, loc wikithing.Path) error {
+	return tx.Bucket([]byte(bucketMediaIDs)).Put([]byte(id.String()), key(loc))
+}
+
+// buildMediaIndex fills in the media index from all of the media, for databases from before there was one
+func buildMediaIndex(tx *bolt.Tx) error {
+	return tx.Bucket([]byte(wtfs.Media)).ForEach(func(k, v []byte) error {
+		var o wikithing.FileObject
+		err := getJSON(tx.Bucket([]byte(wtfs.Media)).Bucket(k), keyCurrent, &o)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return indexMedia(tx, o.ID, wikithing.ParsePath(string(k)))
+	})
+}
+
+// MediaByID finds the media with the given ID
+func (db *DB) MediaByID(id sid.ID) (loc wikithing.Path, a wikithing.FileObject, err error) {
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		k := tx.Bucket([]byte(bucketMediaIDs)).Get([]byte(id.String()))
+		if k == nil {
+			return os.ErrNotExist
+		}
+		loc = wikithing.ParsePath(string(k))
+		err := getJSON(entry(tx, wtfs.Media, loc), keyCurrent, &a)
+		if err != nil {
+			return err
+		}
+		if a.ID != id {
+			return os.ErrNotExist
+		}
+		return nil
+	})
+	return loc, a, err
+}
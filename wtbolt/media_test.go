@@ -0,0 +1,122 @@
+package wtbolt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+func media(hash string) wikithing.FileObject {
+	return wikithing.FileObject{ID: sid.Get(), FileConfig: wikithing.FileConfig{Class: wikithing.FileClassImage, Hash: hash}}
+}
+
+func TestMediaByID(t *testing.T) {
+	db := newDB(t)
+	cat, moved := wikithing.ParsePath("cat"), wikithing.ParsePath("animals/cat")
+	o := media("abc")
+
+	err := db.SaveMedia(cat, o, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := func(want wikithing.Path) {
+		t.Helper()
+		loc, got, err := db.MediaByID(o.ID)
+		if err != nil || !loc.Equal(want) || got.Hash != o.Hash {
+			t.Errorf("looking up the media gave %v %v %v, not %v", loc, got, err, want)
+		}
+	}
+	missing := func() {
+		t.Helper()
+		if loc, _, err := db.MediaByID(o.ID); !os.IsNotExist(err) {
+			t.Errorf("looking up the media gave %v %v when it's not there", loc, err)
+		}
+	}
+	found(cat)
+
+	// moving it is saving it somewhere else and deleting it from where it was
+	err = db.SaveMedia(moved, o, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.DeleteMedia(cat, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found(moved)
+
+	err = db.DeleteMedia(moved, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing()
+
+	l, err := db.DeletedMedia()
+	if err != nil || len(l) != 2 {
+		t.Fatalf("got %+v %v from the trash", l, err)
+	}
+	err = db.RestoreMedia(cat, stampFor(t, l, cat), wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found(cat)
+
+	// something else saved at its path once it's deleted again doesn't get its ID
+	err = db.DeleteMedia(cat, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := media("def")
+	err = db.SaveMedia(cat, other, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing()
+	if loc, _, err := db.MediaByID(other.ID); err != nil || !loc.Equal(cat) {
+		t.Errorf("looking up the other media gave %v %v", loc, err)
+	}
+}
+
+func stampFor(t *testing.T, l []wtfs.Deleted, loc wikithing.Path) string {
+	t.Helper()
+	for _, x := range l {
+		if x.Path.Equal(loc) {
+			return x.Stamp
+		}
+	}
+	t.Fatalf("%v isn't in the trash", loc)
+	return ""
+}
+
+// databases from before there was an index get one built when they're opened
+func TestMediaIndexBuilt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "wiki.db")
+	db, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, o := wikithing.ParsePath("cat"), media("abc")
+	err = db.SaveMedia(loc, o, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.DB.Update(func(tx *bolt.Tx) error { return tx.DeleteBucket([]byte(bucketMediaIDs)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got, _, err := db.MediaByID(o.ID); err != nil || !got.Equal(loc) {
+		t.Errorf("looking up the media gave %v %v", got, err)
+	}
+}
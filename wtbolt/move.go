@@ -0,0 +1,90 @@
+package wtbolt
+
+import (
+	"os"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+// MovePage moves a page and its history to a new path, optionally leaving a redirect behind,
+// its discussion goes with it and it all happens at once so nothing is left half moved
+func (db *DB) MovePage(from, to wikithing.Path, why wikithing.LogEntry, redirect bool) error {
+	stub := wikithing.Article{Redirect: to.String()}
+
+	err := db.DB.Update(func(tx *bolt.Tx) error {
+		err := move(tx, from, to, wtfs.Pages, why)
+		if err != nil {
+			return err
+		}
+
+		// the discussion goes along with the page, if there's one at the new path already nothing moves
+		if b := entry(tx, wtfs.Talk, from); b != nil && b.Get([]byte(keyCurrent)) != nil {
+			err = move(tx, from, to, wtfs.Talk, why)
+			if os.IsExist(err) {
+				return wtfs.ErrTalkExists
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if !redirect {
+			return nil
+		}
+		why.Reason = "Redirect to " + to.String()
+		return update(tx, wtfs.Pages, from, wikithing.LogActionCreate, why, stub)
+	})
+	if err != nil {
+		return err
+	}
+
+	db.PageRemoved(from)
+	if a, err := db.LoadPage(to); err == nil {
+		db.PageChanged(to, a)
+	}
+	if redirect {
+		db.PageChanged(from, stub)
+	}
+	return nil
+}
+
+func move(tx *bolt.Tx, from, to wikithing.Path, pre string, why wikithing.LogEntry) error {
+	if from.Equal(to) {
+		return os.ErrExist
+	}
+	b := entry(tx, pre, from)
+	if b == nil || b.Get([]byte(keyCurrent)) == nil {
+		return os.ErrNotExist
+	}
+	if t := entry(tx, pre, to); t != nil && t.Get([]byte(keyLog)) != nil {
+		return os.ErrExist
+	}
+
+	err := copyBucket(b, tx.Bucket([]byte(pre)), key(to))
+	if err != nil {
+		return err
+	}
+
+	why.When = time.Now().UTC()
+	why.Action = wikithing.LogActionMove
+	why.Ref = wikithing.MoveRef(from, to)
+
+	err = appendLog(entry(tx, pre, to), why)
+	if err != nil {
+		return err
+	}
+
+	// the old path gets a fresh log so there is a record of where the page went
+	err = tx.Bucket([]byte(pre)).DeleteBucket(key(from))
+	if err != nil {
+		return err
+	}
+	b, err = tx.Bucket([]byte(pre)).CreateBucket(key(from))
+	if err != nil {
+		return err
+	}
+	return appendLog(b, why)
+}
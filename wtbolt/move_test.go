@@ -0,0 +1,96 @@
+package wtbolt
+
+import (
+	"os"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+func TestMovePage(t *testing.T) {
+	db := newDB(t)
+	from, to := wikithing.ParsePath("from"), wikithing.ParsePath("to")
+
+	err := db.SavePage(from, article("first"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := db.PageStamp(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.SavePage(from, article("second"), base, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	talk(t, db, from, "about the page")
+
+	err = db.MovePage(from, to, wikithing.LogEntry{Reason: "moving"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := body(t, db, to); b != "second" {
+		t.Errorf("the moved page is %q", b)
+	}
+	revs, err := db.PageRevisions(to)
+	if err != nil || len(revs) != 2 {
+		t.Fatalf("got %v %v for the moved page's revisions", revs, err)
+	}
+	old, err := db.LoadPageRevision(to, revs[0].When)
+	if err != nil || old.Pages[0].Body != "first" {
+		t.Errorf("the history didn't move with the page: %v %v", old, err)
+	}
+	l, err := db.PageLog(to)
+	if n := len(l.Entries); err != nil || n != 3 || l.Entries[n-1].Action != wikithing.LogActionMove {
+		t.Errorf("the moved page's log is %+v %v", l, err)
+	}
+
+	d, err := db.LoadTalk(to)
+	if err != nil || len(d.Comments) != 1 {
+		t.Errorf("the discussion didn't move with the page: %v %v", d, err)
+	}
+	if _, err := db.LoadTalk(from); !os.IsNotExist(err) {
+		t.Errorf("the discussion was left behind too (%v)", err)
+	}
+	a, err := db.LoadPage(from)
+	if err != nil || a.Redirect != to.String() {
+		t.Errorf("no redirect was left behind: %v %v", a, err)
+	}
+
+	// the history of the old path is in the way of moving anything else there
+	err = db.SavePage(wikithing.ParsePath("other"), article("other"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MovePage(wikithing.ParsePath("other"), to, wikithing.LogEntry{}, false); !os.IsExist(err) {
+		t.Errorf("moving onto a page gave %v", err)
+	}
+}
+
+// a discussion at the new path stops the whole move
+func TestMoveOntoTalk(t *testing.T) {
+	db := newDB(t)
+	from, to := wikithing.ParsePath("from"), wikithing.ParsePath("to")
+
+	err := db.SavePage(from, article("page"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	talk(t, db, from, "about the page")
+	talk(t, db, to, "already here")
+
+	if err := db.MovePage(from, to, wikithing.LogEntry{}, true); err != wtfs.ErrTalkExists {
+		t.Fatalf("moving onto a discussion gave %v", err)
+	}
+	if b := body(t, db, from); b != "page" {
+		t.Errorf("the page is %q after the move didn't happen", b)
+	}
+	if _, err := db.LoadPage(to); !os.IsNotExist(err) {
+		t.Errorf("the page is at the new path too (%v)", err)
+	}
+	if l, err := db.PageLog(from); err != nil || len(l.Entries) != 1 {
+		t.Errorf("the log of the page is %+v %v", l, err)
+	}
+}
@@ -0,0 +1,75 @@
+package wtbolt
+
+import (
+	"encoding/json"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (db *DB) LoadPage(loc wikithing.Path) (a wikithing.Article, err error) {
+	return a, db.load(wtfs.Pages, loc, &a)
+}
+
+// PageStamp gets the stamp of the current revision of a page, it's empty if the page doesn't exist
+func (db *DB) PageStamp(loc wikithing.Path) (stamp string, err error) {
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		stamp, err = currentStamp(entry(tx, wtfs.Pages, loc))
+		return err
+	})
+	return stamp, err
+}
+
+// SavePage saves a new revision of a page if it's still at the revision base, otherwise it fails with wtfs.ErrConflict,
+// the check and the save happen in the same transaction
+func (db *DB) SavePage(loc wikithing.Path, a wikithing.Article, base string, why wikithing.LogEntry) error {
+	err := db.DB.Update(func(tx *bolt.Tx) error {
+		cur, err := currentStamp(entry(tx, wtfs.Pages, loc))
+		if err != nil {
+			return err
+		}
+		if cur != base {
+			return wtfs.ErrConflict
+		}
+		return update(tx, wtfs.Pages, loc, wikithing.LogActionUnspecified, why, a)
+	})
+	if err != nil {
+		return err
+	}
+	db.PageChanged(loc, a)
+	return nil
+}
+
+func (db *DB) FollowRedirects(loc wikithing.Path) ([]wikithing.Path, error) {
+	return wtfs.ResolveRedirects(db.LoadPage, loc)
+}
+
+// WalkPages calls fn with every page, parents before their children
+func (db *DB) WalkPages(fn func(loc wikithing.Path, a wikithing.Article) error) error {
+	type page struct {
+		loc wikithing.Path
+		a   wikithing.Article
+	}
+
+	// everything is loaded first so fn is free to use the database
+	var l []page
+	err := db.walk(wtfs.Pages, func(p wikithing.Path, b *bolt.Bucket) error {
+		x := page{loc: p}
+		if err := json.Unmarshal(b.Get([]byte(keyCurrent)), &x.a); err != nil {
+			return err
+		}
+		l = append(l, x)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, x := range l {
+		if err := fn(x.loc, x.a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
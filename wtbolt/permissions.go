@@ -0,0 +1,23 @@
+package wtbolt
+
+import (
+	"os"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+var permissionsPath = wikithing.ParsePath("permissions")
+
+// LoadPermissions loads the permission rules, if none have been saved yet then there are no restrictions
+func (db *DB) LoadPermissions() (ps wikithing.Permissions, err error) {
+	err = db.load(wtfs.Config, permissionsPath, &ps)
+	if os.IsNotExist(err) {
+		return ps, nil
+	}
+	return ps, err
+}
+
+func (db *DB) SavePermissions(ps wikithing.Permissions, why wikithing.LogEntry) error {
+	return db.update(wtfs.Config, permissionsPath, wikithing.LogActionUnspecified, why, ps)
+}
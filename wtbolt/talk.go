@@ -0,0 +1,35 @@
+package wtbolt
+
+import (
+	"os"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (db *DB) LoadTalk(loc wikithing.Path) (d wikithing.Discussion, err error) {
+	return d, db.load(wtfs.Talk, loc, &d)
+}
+
+func (db *DB) TalkLog(loc wikithing.Path) (wikithing.LogFile, error) {
+	return db.loadLog(wtfs.Talk, loc)
+}
+
+// UpdateTalk changes the discussion of a page in a single transaction,
+// fn gets an empty discussion if there isn't one yet, if it fails nothing is saved
+func (db *DB) UpdateTalk(loc wikithing.Path, why wikithing.LogEntry, fn func(d *wikithing.Discussion) error) error {
+	return db.DB.Update(func(tx *bolt.Tx) error {
+		d := new(wikithing.Discussion)
+		err := getJSON(entry(tx, wtfs.Talk, loc), keyCurrent, d)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		err = fn(d)
+		if err != nil {
+			return err
+		}
+		return update(tx, wtfs.Talk, loc, wikithing.LogActionUnspecified, why, d)
+	})
+}
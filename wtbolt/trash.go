@@ -0,0 +1,179 @@
+package wtbolt
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+// trashKey is the name something deleted from a path at a time is kept under in the trash bucket for its kind
+func trashKey(p wikithing.Path, stamp string) []byte { return []byte(p.String() + "@" + stamp) }
+
+// DeletePage moves a page and its history into the trash along with its discussion, pages under it aren't touched
+func (db *DB) DeletePage(loc wikithing.Path, why wikithing.LogEntry) error {
+	err := db.DB.Update(func(tx *bolt.Tx) error {
+		at := time.Now().UTC()
+		err := deleteEntry(tx, loc, wtfs.Pages, why, at)
+		if err != nil {
+			return err
+		}
+
+		// the discussion is kept under the same stamp so it comes back with the page
+		if b := entry(tx, wtfs.Talk, loc); b != nil && b.Get([]byte(keyCurrent)) != nil {
+			return deleteEntry(tx, loc, wtfs.Talk, why, at)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	db.PageRemoved(loc)
+	return nil
+}
+
+// RestorePage brings a page back out of the trash, failing with os.ErrExist if something else is at its path now
+func (db *DB) RestorePage(loc wikithing.Path, stamp string, why wikithing.LogEntry) error {
+	err := db.DB.Update(func(tx *bolt.Tx) error {
+		err := restoreEntry(tx, loc, wtfs.Pages, stamp, why)
+		if err != nil {
+			return err
+		}
+
+		// unless there's a new discussion at the path, which it stays in the trash for
+		err = restoreEntry(tx, loc, wtfs.Talk, stamp, why)
+		if err != nil && !os.IsNotExist(err) && !os.IsExist(err) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if a, err := db.LoadPage(loc); err == nil {
+		db.PageChanged(loc, a)
+	}
+	return nil
+}
+
+// DeletedPages lists every page in the trash, most recently deleted first
+func (db *DB) DeletedPages() ([]wtfs.Deleted, error) {
+	return db.listTrash(wtfs.Pages)
+}
+
+func (db *DB) DeleteMedia(loc wikithing.Path, why wikithing.LogEntry) error {
+	return db.delete(loc, wtfs.Media, why)
+}
+
+func (db *DB) RestoreMedia(loc wikithing.Path, stamp string, why wikithing.LogEntry) error {
+	return db.DB.Update(func(tx *bolt.Tx) error {
+		err := restoreEntry(tx, loc, wtfs.Media, stamp, why)
+		if err != nil {
+			return err
+		}
+		var o wikithing.FileObject
+		err = getJSON(entry(tx, wtfs.Media, loc), keyCurrent, &o)
+		if err != nil {
+			return err
+		}
+		return indexMedia(tx, o.ID, loc)
+	})
+}
+
+func (db *DB) DeletedMedia() ([]wtfs.Deleted, error) {
+	return db.listTrash(wtfs.Media)
+}
+
+func (db *DB) delete(p wikithing.Path, pre string, why wikithing.LogEntry) error {
+	return db.DB.Update(func(tx *bolt.Tx) error {
+		return deleteEntry(tx, p, pre, why, time.Now().UTC())
+	})
+}
+
+// deleteEntry moves something into the trash, at is when it's logged as deleted which is the stamp it's kept under
+func deleteEntry(tx *bolt.Tx, p wikithing.Path, pre string, why wikithing.LogEntry, at time.Time) error {
+	b := entry(tx, pre, p)
+	if b == nil || b.Get([]byte(keyCurrent)) == nil {
+		return os.ErrNotExist
+	}
+
+	why.When = at
+	why.Action = wikithing.LogActionDelete
+	err := appendLog(b, why)
+	if err != nil {
+		return err
+	}
+
+	trash, err := tx.Bucket([]byte(wtfs.Trash)).CreateBucketIfNotExists([]byte(pre))
+	if err != nil {
+		return err
+	}
+	err = copyBucket(b, trash, trashKey(p, at.Format(wtfs.TimeFormat)))
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(pre)).DeleteBucket(key(p))
+}
+
+func restoreEntry(tx *bolt.Tx, p wikithing.Path, pre, stamp string, why wikithing.LogEntry) error {
+	if err := wtfs.CheckStamp(stamp); err != nil {
+		return err
+	}
+	trash := tx.Bucket([]byte(wtfs.Trash)).Bucket([]byte(pre))
+	if trash == nil || trash.Bucket(trashKey(p, stamp)) == nil {
+		return os.ErrNotExist
+	}
+	if b := entry(tx, pre, p); b != nil && b.Get([]byte(keyLog)) != nil {
+		return os.ErrExist
+	}
+
+	err := copyBucket(trash.Bucket(trashKey(p, stamp)), tx.Bucket([]byte(pre)), key(p))
+	if err != nil {
+		return err
+	}
+	err = trash.DeleteBucket(trashKey(p, stamp))
+	if err != nil {
+		return err
+	}
+
+	why.When = time.Now().UTC()
+	why.Action = wikithing.LogActionRestore
+	return appendLog(entry(tx, pre, p), why)
+}
+
+func (db *DB) listTrash(pre string) (out []wtfs.Deleted, err error) {
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		trash := tx.Bucket([]byte(wtfs.Trash)).Bucket([]byte(pre))
+		if trash == nil {
+			return nil
+		}
+		return trash.ForEach(func(k, v []byte) error {
+			i := strings.LastIndexByte(string(k), '@')
+			if v != nil || i < 0 {
+				return nil
+			}
+			d := wtfs.Deleted{
+				Path:  wikithing.ParsePath(string(k[:i])),
+				Stamp: string(k[i+1:]),
+			}
+
+			log, err := loadLog(trash.Bucket(k))
+			if err != nil {
+				return err
+			}
+			if n := len(log.Entries); n > 0 {
+				d.Entry = log.Entries[n-1]
+			}
+
+			out = append(out, d)
+			return nil
+		})
+	})
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Stamp > out[j].Stamp })
+	return out, err
+}
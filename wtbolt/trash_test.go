@@ -0,0 +1,70 @@
+package wtbolt
+
+import (
+	"os"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+func TestDeleteRestore(t *testing.T) {
+	db := newDB(t)
+	loc := wikithing.ParsePath("gone")
+
+	err := db.SavePage(loc, article("page"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	talk(t, db, loc, "about the page")
+
+	err = db.DeletePage(loc, wikithing.LogEntry{Reason: "not wanted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.LoadPage(loc); !os.IsNotExist(err) {
+		t.Fatalf("the page is still there after deleting it (%v)", err)
+	}
+	if _, err := db.LoadTalk(loc); !os.IsNotExist(err) {
+		t.Errorf("the discussion is still there after deleting the page (%v)", err)
+	}
+	if err := db.DeletePage(loc, wikithing.LogEntry{}); !os.IsNotExist(err) {
+		t.Errorf("deleting it again gave %v", err)
+	}
+
+	l, err := db.DeletedPages()
+	if err != nil || len(l) != 1 || l[0].Entry.Reason != "not wanted" {
+		t.Fatalf("got %+v %v from the trash", l, err)
+	}
+
+	if err := db.RestorePage(loc, "../gone", wikithing.LogEntry{}); err != wtfs.ErrBadStamp {
+		t.Errorf("restoring with a bad stamp gave %v", err)
+	}
+
+	err = db.SavePage(loc, article("new"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RestorePage(loc, l[0].Stamp, wikithing.LogEntry{}); !os.IsExist(err) {
+		t.Errorf("restoring over another page gave %v", err)
+	}
+	err = db.DeletePage(loc, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.RestorePage(loc, l[0].Stamp, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := body(t, db, loc); b != "page" {
+		t.Errorf("the page came back as %q", b)
+	}
+	d, err := db.LoadTalk(loc)
+	if err != nil || len(d.Comments) != 1 {
+		t.Errorf("the discussion didn't come back with the page: %v %v", d, err)
+	}
+	if l, err := db.DeletedPages(); err != nil || len(l) != 1 {
+		t.Errorf("only the page deleted second should be left in the trash, got %+v %v", l, err)
+	}
+}
@@ -0,0 +1,63 @@
+package wtbolt
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ListChildren lists everything directly under a page, sorted by name,
+// the zero path lists everything at the top
+//
+// there are no directories here, namespaces are worked out from the paths of everything under the page
+func (db *DB) ListChildren(loc wikithing.Path) ([]wtfs.Child, error) {
+	pre := ""
+	if !loc.IsZero() {
+		pre = loc.String() + "."
+	}
+
+	found := map[string]*wtfs.Child{}
+	var out []*wtfs.Child
+	err := db.DB.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(wtfs.Pages)).Cursor()
+		for k, v := c.Seek([]byte(pre)); k != nil && bytes.HasPrefix(k, []byte(pre)); k, v = c.Next() {
+			if v != nil {
+				continue
+			}
+			name, rest := string(k[len(pre):]), ""
+			if i := strings.IndexByte(name, '.'); i >= 0 {
+				name, rest = name[:i], name[i+1:]
+			}
+			if name == "" {
+				continue
+			}
+
+			x, ok := found[name]
+			if !ok {
+				x = &wtfs.Child{Path: loc.Child(name)}
+				found[name] = x
+				out = append(out, x)
+			}
+			if rest != "" {
+				x.HasChildren = true
+			} else if c.Bucket().Bucket(k).Get([]byte(keyCurrent)) != nil {
+				x.Exists = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]wtfs.Child, len(out))
+	for i, x := range out {
+		l[i] = *x
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Path.Base() < l[j].Path.Base() })
+	return l, nil
+}
@@ -0,0 +1,67 @@
+package wtbolt
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+var errStopWalk = errors.New("stop walking")
+
+func userPath(name string) wikithing.Path { return wikithing.ParsePath(name) }
+
+func (db *DB) LoadUser(name string) (u wikithing.User, err error) {
+	if !wikithing.ValidUsername(name) {
+		return u, os.ErrNotExist
+	}
+	return u, db.load(wtfs.Users, userPath(name), &u)
+}
+
+func (db *DB) SaveUser(u wikithing.User, why wikithing.LogEntry) error {
+	return db.update(wtfs.Users, userPath(u.Name), wikithing.LogActionUnspecified, why, u)
+}
+
+// CreateUser saves a new user, failing with os.ErrExist if the name is already taken
+func (db *DB) CreateUser(u wikithing.User, why wikithing.LogEntry) error {
+	return db.update(wtfs.Users, userPath(u.Name), wikithing.LogActionCreate, why, u)
+}
+
+// UserByID looks through all the users for the one with the given ID
+func (db *DB) UserByID(id sid.ID) (u wikithing.User, err error) {
+	err = db.walk(wtfs.Users, func(p wikithing.Path, b *bolt.Bucket) error {
+		var x wikithing.User
+		if err := json.Unmarshal(b.Get([]byte(keyCurrent)), &x); err != nil {
+			return err
+		}
+		if x.ID != id {
+			return nil
+		}
+		u = x
+		return errStopWalk
+	})
+	switch err {
+	case errStopWalk:
+		return u, nil
+	case nil:
+		return u, os.ErrNotExist
+	}
+	return u, err
+}
+
+// ListUsers loads every user
+func (db *DB) ListUsers() (l []wikithing.User, err error) {
+	err = db.walk(wtfs.Users, func(p wikithing.Path, b *bolt.Bucket) error {
+		var x wikithing.User
+		if err := json.Unmarshal(b.Get([]byte(keyCurrent)), &x); err != nil {
+			return err
+		}
+		l = append(l, x)
+		return nil
+	})
+	return l, err
+}
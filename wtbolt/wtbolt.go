@@ -0,0 +1,192 @@
+// Package wtbolt keeps all of the wiki's data in a single bbolt database file.
+//
+// It stores the same things in the same way as wtfs, current versions, every revision and logs, as JSON,
+// but each kind of data is a bucket with a bucket in it for every path instead of a directory tree,
+// and every change happens in a single transaction so there are no lockfiles to take or leave behind
+package wtbolt
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Keys inside of the bucket for a path
+const (
+	keyCurrent = "current"
+	keyLog     = "log"
+	// revisions are kept under this followed by the stamp of the revision, the current one included
+	keyRevision = "rev:"
+)
+
+// DB is a bbolt database holding a wiki
+type DB struct {
+	DB *bolt.DB
+
+	wtfs.Hooks
+}
+
+var _ wtfs.Store = (*DB)(nil)
+
+// Open opens or creates a database file
+func Open(file string) (*DB, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, x := range []string{wtfs.Pages, wtfs.Media, wtfs.Talk, wtfs.Users, wtfs.Config, wtfs.Trash} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(x)); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket([]byte(bucketMediaIDs)) != nil {
+			return nil
+		}
+		if _, err := tx.CreateBucket([]byte(bucketMediaIDs)); err != nil {
+			return err
+		}
+		return buildMediaIndex(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{DB: db}, nil
+}
+
+// Close closes the database
+func (db *DB) Close() error {
+	return db.DB.Close()
+}
+
+func key(p wikithing.Path) []byte { return []byte(p.String()) }
+
+// entry gets the bucket for a path, or nil if nothing has ever been there
+func entry(tx *bolt.Tx, pre string, p wikithing.Path) *bolt.Bucket {
+	return tx.Bucket([]byte(pre)).Bucket(key(p))
+}
+
+func getJSON(b *bolt.Bucket, k string, dat interface{}) error {
+	if b == nil {
+		return os.ErrNotExist
+	}
+	v := b.Get([]byte(k))
+	if v == nil {
+		return os.ErrNotExist
+	}
+	return json.Unmarshal(v, dat)
+}
+
+func putJSON(b *bolt.Bucket, k string, dat interface{}) error {
+	v, err := json.Marshal(dat)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(k), v)
+}
+
+// load loads the current version of something
+func (db *DB) load(pre string, p wikithing.Path, dat interface{}) error {
+	return db.DB.View(func(tx *bolt.Tx) error {
+		return getJSON(entry(tx, pre, p), keyCurrent, dat)
+	})
+}
+
+func loadLog(b *bolt.Bucket) (l wikithing.LogFile, err error) {
+	return l, getJSON(b, keyLog, &l)
+}
+
+func appendLog(b *bolt.Bucket, le wikithing.LogEntry) error {
+	l, err := loadLog(b)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l.Entries = append(l.Entries, le)
+	return putJSON(b, keyLog, l)
+}
+
+// currentStamp gets the stamp of the current revision, it's empty if there isn't one
+func currentStamp(b *bolt.Bucket) (string, error) {
+	if b == nil || b.Get([]byte(keyCurrent)) == nil {
+		return "", nil
+	}
+	l, err := loadLog(b)
+	if err != nil {
+		return "", err
+	}
+	for i := len(l.Entries) - 1; i >= 0; i-- {
+		if wtfs.ChangesContent(l.Entries[i].Action) {
+			return l.Entries[i].When.Format(wtfs.TimeFormat), nil
+		}
+	}
+	return "", nil
+}
+
+// update saves a new version of something in a transaction that's already open,
+// it works the same as in wtfs, if the action is unspecified it's logged as a create or edit,
+// and if it's a create then it fails with os.ErrExist when there is already something there
+func update(tx *bolt.Tx, pre string, p wikithing.Path, action uint, why wikithing.LogEntry, dat interface{}) error {
+	b, err := tx.Bucket([]byte(pre)).CreateBucketIfNotExists(key(p))
+	if err != nil {
+		return err
+	}
+
+	why.Action = wikithing.LogActionEdit
+	if b.Get([]byte(keyCurrent)) == nil {
+		why.Action = wikithing.LogActionCreate
+	} else if action == wikithing.LogActionCreate {
+		return os.ErrExist
+	}
+	if action != wikithing.LogActionUnspecified {
+		why.Action = action
+	}
+	why.When = time.Now().UTC()
+
+	err = appendLog(b, why)
+	if err != nil {
+		return err
+	}
+	err = putJSON(b, keyRevision+why.When.Format(wtfs.TimeFormat), dat)
+	if err != nil {
+		return err
+	}
+	return putJSON(b, keyCurrent, dat)
+}
+
+func (db *DB) update(pre string, p wikithing.Path, action uint, why wikithing.LogEntry, dat interface{}) error {
+	return db.DB.Update(func(tx *bolt.Tx) error {
+		return update(tx, pre, p, action, why, dat)
+	})
+}
+
+// walk calls fn with every path of a kind that has a current version, parents before their children,
+// fn is called inside of the transaction so it can't use the database itself
+func (db *DB) walk(pre string, fn func(p wikithing.Path, b *bolt.Bucket) error) error {
+	return db.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(pre)).ForEach(func(k, v []byte) error {
+			b := tx.Bucket([]byte(pre)).Bucket(k)
+			if v != nil || b == nil || b.Get([]byte(keyCurrent)) == nil {
+				return nil
+			}
+			return fn(wikithing.ParsePath(string(k)), b)
+		})
+	})
+}
+
+// copyBucket copies everything in a bucket for a path into another one, which gets made if needed
+func copyBucket(from *bolt.Bucket, to *bolt.Bucket, k []byte) error {
+	dst, err := to.CreateBucketIfNotExists(k)
+	if err != nil {
+		return err
+	}
+	return from.ForEach(func(k, v []byte) error {
+		return dst.Put(k, append([]byte(nil), v...))
+	})
+}
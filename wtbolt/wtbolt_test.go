@@ -0,0 +1,77 @@
+package wtbolt
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+func article(body string) wikithing.Article {
+	return wikithing.Article{Pages: []wikithing.Page{{Title: "Test", Body: body, Format: wikithing.FormatMarkdown}}}
+}
+
+// newDB opens a database in a file that lasts for the test
+func newDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "wiki.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func body(t *testing.T, db *DB, loc wikithing.Path) string {
+	t.Helper()
+	a, err := db.LoadPage(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(a.Pages[0].Body)
+}
+
+func talk(t *testing.T, db *DB, loc wikithing.Path, comment string) {
+	t.Helper()
+	err := db.UpdateTalk(loc, wikithing.LogEntry{}, func(d *wikithing.Discussion) error {
+		d.Comments = append(d.Comments, wikithing.Comment{Body: comment})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveConflict(t *testing.T) {
+	db := newDB(t)
+	loc := wikithing.ParsePath("some/page")
+
+	err := db.SavePage(loc, article("first"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := db.PageStamp(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.SavePage(loc, article("theirs"), base, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.SavePage(loc, article("mine"), base, wikithing.LogEntry{}); err != wtfs.ErrConflict {
+		t.Errorf("saving over someone else's edit gave %v", err)
+	}
+	if err := db.SavePage(loc, article("mine"), "", wikithing.LogEntry{}); err != wtfs.ErrConflict {
+		t.Errorf("creating a page that's there already gave %v", err)
+	}
+	if b := body(t, db, loc); b != "theirs" {
+		t.Errorf("the page is %q after conflicting saves", b)
+	}
+	revs, err := db.PageRevisions(loc)
+	if err != nil || len(revs) != 2 {
+		t.Errorf("got %v %v for the revisions", revs, err)
+	}
+}
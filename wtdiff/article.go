@@ -0,0 +1,90 @@
+package wtdiff
+
+import (
+	"strings"
+
+	"git.lan/wikithing"
+)
+
+// PageDiff is the differences between two versions of a single page
+type PageDiff struct {
+	Title []Edit
+	Body  []Line
+	// Table is a line diff of the table, with the title and then one line for each value of each field
+	Table []Line
+
+	FormatFrom string
+	FormatTo   string
+}
+
+// Changed reports whether there are any differences between the two pages
+func (p PageDiff) Changed() bool {
+	for _, x := range p.Title {
+		if x.Kind != Equal {
+			return true
+		}
+	}
+	return p.FormatFrom != p.FormatTo || Changed(p.Body) || Changed(p.Table)
+}
+
+// Pages diffs two versions of a page
+func Pages(a, b wikithing.Page) PageDiff {
+	return PageDiff{
+		Title:      Words(a.Title, b.Title),
+		Body:       Lines(a.Body, b.Body),
+		Table:      Lines(tableLines(a.Table), tableLines(b.Table)),
+		FormatFrom: a.Format,
+		FormatTo:   b.Format,
+	}
+}
+
+func tableLines(t wikithing.Table) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	l := []string{"title: " + t.Title}
+	for _, x := range t.Fields {
+		for _, v := range x.Rows {
+			l = append(l, x.Key+": "+v)
+		}
+	}
+	return strings.Join(l, "\n")
+}
+
+// ArticleDiff is the differences between two versions of an article, pages are matched up by their position
+type ArticleDiff struct {
+	Pages []PageDiff
+}
+
+// Articles diffs two versions of an article, any pages missing from one side are diffed against an empty page
+func Articles(a, b wikithing.Article) ArticleDiff {
+	n := len(a.Pages)
+	if len(b.Pages) > n {
+		n = len(b.Pages)
+	}
+
+	d := ArticleDiff{Pages: make([]PageDiff, n)}
+	for i := range d.Pages {
+		var pa, pb wikithing.Page
+		if i < len(a.Pages) {
+			pa = a.Pages[i]
+		}
+		if i < len(b.Pages) {
+			pb = b.Pages[i]
+		}
+		d.Pages[i] = Pages(pa, pb)
+	}
+
+	return d
+}
+
+// Changed reports whether there are any differences between the two articles
+func (d ArticleDiff) Changed() bool {
+	for _, x := range d.Pages {
+		if x.Changed() {
+			return true
+		}
+	}
+	return false
+}
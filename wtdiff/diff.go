@@ -0,0 +1,297 @@
+// Package wtdiff works out the differences between two versions of some text or an article
+package wtdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// OpKind is what happened to a piece of text between two versions
+type OpKind uint
+
+// Op kinds
+const (
+	Equal OpKind = iota
+	Insert
+	Delete
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Edit is a single line or word in a diff
+type Edit struct {
+	Kind OpKind
+	Text string
+}
+
+// Strings diffs two lists of strings, using the myers diff algorithm
+func Strings(a, b []string) []Edit {
+	// the bits at the start and end that are the same don't need to go through the full algorithm
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	out := make([]Edit, 0, len(a)+len(b))
+	for _, x := range a[:pre] {
+		out = append(out, Edit{Equal, x})
+	}
+	out = append(out, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, x := range a[len(a)-suf:] {
+		out = append(out, Edit{Equal, x})
+	}
+
+	return out
+}
+
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v holds the furthest x reached on each diagonal k, offset so k can be negative
+	off := max + 1
+	v := make([]int, 2*max+3)
+	// trace keeps the part of v that matters from the start of each round, for walking back through afterwards
+	trace := make([][]int, 0, 8)
+
+	for d := 0; d <= max; d++ {
+		t := make([]int, 2*d+3)
+		copy(t, v[off-d-1:off+d+2])
+		trace = append(trace, t)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+
+	// can't actually get here, d = n+m always reaches the end
+	return nil
+}
+
+func backtrack(a, b []string, trace [][]int) []Edit {
+	x, y := len(a), len(b)
+	out := make([]Edit, 0, x+y)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		t := trace[d]
+		at := func(k int) int { return t[k+d+1] }
+
+		k := x - y
+		var pk int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := at(pk)
+		py := px - pk
+
+		for x > px && y > py {
+			out = append(out, Edit{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == px {
+			out = append(out, Edit{Insert, b[y-1]})
+		} else {
+			out = append(out, Edit{Delete, a[x-1]})
+		}
+		x, y = px, py
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// SplitLines splits text up into lines for diffing
+func SplitLines(s string) []string {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// SplitWords splits text up into words, runs of spaces and single punctuation characters, for diffing
+func SplitWords(s string) []string {
+	var out []string
+
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+
+	start, last := 0, -1
+	for i, r := range s {
+		c := class(r)
+		if i > start && (c != last || c == 0) {
+			out = append(out, s[start:i])
+			start = i
+		}
+		last = c
+	}
+	if start < len(s) {
+		out = append(out, s[start:])
+	}
+
+	return out
+}
+
+// Words diffs two bits of text word by word
+func Words(a, b string) []Edit {
+	return Strings(SplitWords(a), SplitWords(b))
+}
+
+// Line is a single line in a line diff
+type Line struct {
+	Kind OpKind
+	Text string
+
+	// Words is set on lines that were changed into another line rather than just added or removed,
+	// for deleted lines it has the equal and deleted words, and for inserted lines the equal and inserted ones
+	Words []Edit
+}
+
+// Lines diffs two bits of text line by line,
+// removed lines that are directly replaced by added lines are also diffed by word
+func Lines(a, b string) []Line {
+	edits := Strings(SplitLines(a), SplitLines(b))
+	out := make([]Line, 0, len(edits))
+
+	for i := 0; i < len(edits); {
+		if edits[i].Kind == Equal {
+			out = append(out, Line{Kind: Equal, Text: edits[i].Text})
+			i++
+			continue
+		}
+
+		// gather up a run of changes and pair off the deletes with the inserts
+		var del, ins []string
+		for ; i < len(edits) && edits[i].Kind != Equal; i++ {
+			if edits[i].Kind == Delete {
+				del = append(del, edits[i].Text)
+			} else {
+				ins = append(ins, edits[i].Text)
+			}
+		}
+
+		dl := make([]Line, len(del))
+		il := make([]Line, len(ins))
+		for j, x := range del {
+			dl[j] = Line{Kind: Delete, Text: x}
+		}
+		for j, x := range ins {
+			il[j] = Line{Kind: Insert, Text: x}
+		}
+		for j := 0; j < len(del) && j < len(ins); j++ {
+			for _, w := range Words(del[j], ins[j]) {
+				if w.Kind != Insert {
+					dl[j].Words = appendEdit(dl[j].Words, w)
+				}
+				if w.Kind != Delete {
+					il[j].Words = appendEdit(il[j].Words, w)
+				}
+			}
+		}
+
+		out = append(out, dl...)
+		out = append(out, il...)
+	}
+
+	return out
+}
+
+// appendEdit adds an edit onto a list, merging it into the last one if they're the same kind
+func appendEdit(l []Edit, e Edit) []Edit {
+	if len(l) > 0 && l[len(l)-1].Kind == e.Kind {
+		l[len(l)-1].Text += e.Text
+		return l
+	}
+	return append(l, e)
+}
+
+// Changed reports whether there are any differences in a line diff
+func Changed(l []Line) bool {
+	for _, x := range l {
+		if x.Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunks splits a line diff into groups of changes with n lines of unchanged context around them,
+// dropping any unchanged lines further away than that
+func Hunks(l []Line, n int) [][]Line {
+	var out [][]Line
+
+	keep := make([]bool, len(l))
+	for i, x := range l {
+		if x.Kind == Equal {
+			continue
+		}
+		for j := i - n; j <= i+n; j++ {
+			if j >= 0 && j < len(l) {
+				keep[j] = true
+			}
+		}
+	}
+
+	var cur []Line
+	for i, x := range l {
+		if !keep[i] {
+			if cur != nil {
+				out = append(out, cur)
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, x)
+	}
+	if cur != nil {
+		out = append(out, cur)
+	}
+
+	return out
+}
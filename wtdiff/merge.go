@@ -0,0 +1,149 @@
+package wtdiff
+
+import "strings"
+
+// MergeChunk is a piece of a three way merge
+type MergeChunk struct {
+	// Conflict is set when both sides changed the same part in different ways
+	Conflict bool
+	// Lines is the merged result, for conflicts it's empty and it's up to someone to pick from Ours and Theirs
+	Lines []string
+
+	Base, Ours, Theirs []string
+}
+
+// Merge is the result of merging two sets of changes to the same text
+type Merge struct {
+	Chunks []MergeChunk
+}
+
+// Conflicts reports whether there are any parts of a merge that couldn't be done automatically
+func (m Merge) Conflicts() bool {
+	for _, x := range m.Chunks {
+		if x.Conflict {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflict markers put around the two sides of a conflict by Text
+const (
+	MarkerOurs   = "<<<<<<< yours"
+	MarkerSplit  = "======="
+	MarkerTheirs = ">>>>>>> theirs"
+)
+
+// Text puts a merge back together into text,
+// conflicts have both sides included between markers like with git so they can be sorted out by hand
+func (m Merge) Text() string {
+	var out []string
+	for _, x := range m.Chunks {
+		if !x.Conflict {
+			out = append(out, x.Lines...)
+			continue
+		}
+		out = append(out, MarkerOurs)
+		out = append(out, x.Ours...)
+		out = append(out, MarkerSplit)
+		out = append(out, x.Theirs...)
+		out = append(out, MarkerTheirs)
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// Merge3 merges the changes made in ours and theirs, both starting from base, line by line.
+// Parts where only one side changed take that side, and parts both sides changed the same way take the change,
+// anything else is a conflict
+func Merge3(base, ours, theirs string) Merge {
+	b, o, t := SplitLines(base), SplitLines(ours), SplitLines(theirs)
+	mo, mt := matches(b, o), matches(b, t)
+
+	var m Merge
+	i, j, k := 0, 0, 0
+	for i < len(b) || j < len(o) || k < len(t) {
+		// lines that are the same everywhere are kept as they are
+		start := i
+		for i < len(b) && mo[i] == j && mt[i] == k {
+			i, j, k = i+1, j+1, k+1
+		}
+		if i > start {
+			m.add(MergeChunk{Lines: b[start:i], Base: b[start:i], Ours: b[start:i], Theirs: b[start:i]})
+			continue
+		}
+
+		// otherwise everything up until the next base line both sides still have is a change on one side or both
+		next := i
+		for next < len(b) && (mo[next] < 0 || mt[next] < 0) {
+			next++
+		}
+		c := MergeChunk{Base: b[i:next]}
+		if next < len(b) {
+			c.Ours, c.Theirs = o[j:mo[next]], t[k:mt[next]]
+			j, k = mo[next], mt[next]
+		} else {
+			c.Ours, c.Theirs = o[j:], t[k:]
+			j, k = len(o), len(t)
+		}
+		i = next
+
+		switch {
+		case same(c.Ours, c.Base):
+			c.Lines = c.Theirs
+		case same(c.Theirs, c.Base), same(c.Ours, c.Theirs):
+			c.Lines = c.Ours
+		default:
+			c.Conflict = true
+		}
+		m.add(c)
+	}
+
+	return m
+}
+
+// add adds a chunk to a merge, joining it onto the last one if neither are conflicts
+func (m *Merge) add(c MergeChunk) {
+	if n := len(m.Chunks); n > 0 && !c.Conflict && !m.Chunks[n-1].Conflict {
+		l := &m.Chunks[n-1]
+		l.Lines = append(l.Lines[:len(l.Lines):len(l.Lines)], c.Lines...)
+		l.Base = append(l.Base[:len(l.Base):len(l.Base)], c.Base...)
+		l.Ours = append(l.Ours[:len(l.Ours):len(l.Ours)], c.Ours...)
+		l.Theirs = append(l.Theirs[:len(l.Theirs):len(l.Theirs)], c.Theirs...)
+		return
+	}
+	m.Chunks = append(m.Chunks, c)
+}
+
+// matches works out which line of b each line of a is the same as, or -1 for lines b doesn't have
+func matches(a, b []string) []int {
+	m := make([]int, len(a))
+	i, j := 0, 0
+	for _, x := range Strings(a, b) {
+		switch x.Kind {
+		case Equal:
+			m[i] = j
+			i, j = i+1, j+1
+		case Delete:
+			m[i] = -1
+			i++
+		case Insert:
+			j++
+		}
+	}
+	return m
+}
+
+func same(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -18,6 +18,8 @@ func (e ErrType) String() (s string) {
 		s = "invalid input"
 	case ErrUnsupported:
 		s = "unsupported"
+	case ErrConflict:
+		s = "conflict"
 	}
 	return s
 }
@@ -28,6 +30,8 @@ const (
 	ErrAuthFailed
 	ErrInvalidInput
 	ErrUnsupported
+	// ErrConflict is for changes that clash with something someone else did in the meantime
+	ErrConflict
 )
 
 type Err struct {
@@ -0,0 +1,315 @@
+package wtfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"git.lan/wikithing"
+)
+
+// Problem is something wrong with a managed file that Check found
+type Problem struct {
+	// Dir is the directory of the managed file
+	Dir     string
+	Problem string
+	// Repaired is whether it was fixed
+	Repaired bool
+}
+
+func (p Problem) String() string {
+	if p.Repaired {
+		return p.Dir + ": " + p.Problem + " (repaired)"
+	}
+	return p.Dir + ": " + p.Problem
+}
+
+// Check goes through every managed file looking for anything left half done, like when the wiki stops in the middle of saving something,
+// if repair is set it fixes what it can. It should be run before anything else starts using the filesystem
+//
+// saves copy the current version to its revision file, replace the current version and then add to the log, in that order,
+// so a save that didn't finish leaves a revision file newer than anything in the log, which is put back as the current version,
+// or a current version the log doesn't know about, which gets logged so it's not lost
+func (f *Filesystem) Check(repair bool) ([]Problem, error) {
+	var out []Problem
+	for _, x := range []string{Pages, Media, Talk, Users, Config} {
+		err := f.checkDir(x, "", repair, &out)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func (f *Filesystem) checkDir(pre, dir string, repair bool, out *[]Problem) error {
+	full := path.Join(pre, dir)
+	l, err := f.FS.ReadDir(full)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, x := range l {
+		if x.IsDir() {
+			err := f.checkDir(pre, path.Join(dir, x.Name()), repair, out)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		files = append(files, x.Name())
+	}
+
+	if dir != "" && len(files) > 0 {
+		c := checker{f: f, p: wikithing.ParsePath(dir), pre: pre, dir: full, repair: repair, out: out}
+		return c.check(files)
+	}
+	return nil
+}
+
+// checker checks a single managed file
+type checker struct {
+	f      *Filesystem
+	p      wikithing.Path
+	pre    string
+	dir    string
+	repair bool
+	out    *[]Problem
+}
+
+// problem reports a problem, fix is called to repair it if repairing and it's not nil
+func (c *checker) problem(fix func() error, format string, args ...interface{}) error {
+	pr := Problem{Dir: c.dir, Problem: fmt.Sprintf(format, args...)}
+	if c.repair && fix != nil {
+		if err := fix(); err != nil {
+			return err
+		}
+		pr.Repaired = true
+	}
+	*c.out = append(*c.out, pr)
+	return nil
+}
+
+func (c *checker) file(name string) string { return path.Join(c.dir, name) }
+
+func (c *checker) exists(name string) bool {
+	_, err := c.f.FS.Stat(c.file(name))
+	return err == nil
+}
+
+func (c *checker) check(files []string) error {
+	// stamps has the names of the revision files and when they're from
+	stamps := map[string]time.Time{}
+	other := false
+	for _, x := range files {
+		switch {
+		case strings.Contains(x, Extension+tmpSuffix):
+			// temporary files only stick around if writing them didn't finish
+			name := x
+			err := c.problem(func() error { return c.f.FS.Remove(c.file(name)) }, "the unfinished write %v was left behind", x)
+			if err != nil {
+				return err
+			}
+		case x == manLock+Extension, x == manLog+Extension:
+		case strings.HasSuffix(x, Extension):
+			other = true
+			if at, ok := parseStamp(strings.TrimSuffix(x, Extension)); ok {
+				stamps[strings.TrimSuffix(x, Extension)] = at
+			}
+		}
+	}
+
+	if !c.exists(manLog + Extension) {
+		if other {
+			return c.problem(nil, "there are files but no log")
+		}
+		return nil
+	}
+
+	var log wikithing.LogFile
+	err := c.f.readJSON(c.file(manLog+Extension), &log)
+	if err != nil {
+		return c.problem(nil, "the log can't be read: %v", err)
+	}
+
+	err = c.checkCurrent()
+	if err != nil {
+		return err
+	}
+
+	// revision k is kept under the stamp of revision k+1, so every revision but the first has a file,
+	// want has their names which are in oldTimeFormat for ones saved before TimeFormat changed
+	want := map[string]bool{}
+	var last time.Time
+	// content has the positions of the log entries that made revisions
+	var content []int
+	for i, x := range log.Entries {
+		if ChangesContent(x.Action) {
+			if len(content) > 0 {
+				want[c.f.whenName(c.p, c.pre, x.When)] = true
+			}
+			content = append(content, i)
+		}
+		if x.When.After(last) {
+			last = x.When
+		}
+	}
+
+	names := make([]string, 0, len(stamps))
+	for x := range stamps {
+		names = append(names, x)
+	}
+	sort.Slice(names, func(i, j int) bool { return stamps[names[i]].Before(stamps[names[j]]) })
+	for _, stamp := range names {
+		if want[stamp] {
+			continue
+		}
+		at, name := stamps[stamp], stamp+Extension
+
+		switch {
+		case !c.exists(manCurrent+Extension) || at.After(last):
+			err = c.problem(func() error { return c.rename(name, manCurrent+Extension) },
+				"the save at %v didn't finish, the version from before it was put back", stamp)
+		default:
+			err = c.problem(nil, "the revision file %v isn't in the log", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	current := c.exists(manCurrent + Extension)
+	switch live := c.live(log); {
+	case current && !live:
+		le := wikithing.LogEntry{
+			Action: wikithing.LogActionCreate,
+			Reason: "Found after saving it didn't finish",
+			When:   time.Now().UTC(),
+		}
+		// it was there before, like when it was being brought back out of the trash
+		if len(content) > 0 {
+			le.Action = wikithing.LogActionRestore
+		}
+		err = c.problem(func() error {
+			log.Entries = append(log.Entries, le)
+			return c.f.writeJSONFile(c.file(manLog+Extension), log)
+		}, "the current version isn't in the log")
+
+	case !current && live && len(content) > 0:
+		n := content[len(content)-1]
+		// this is only left behind by saves from before TimeFormat changed so it's most likely in oldTimeFormat
+		stamp := c.f.whenName(c.p, c.pre, log.Entries[n].When)
+		name := stamp + Extension
+		if !c.exists(name) {
+			err = c.problem(nil, "the current version is missing")
+			break
+		}
+
+		// saves used to move the current version out of the way before writing the new one
+		err = c.problem(func() error {
+			log.Entries = append(log.Entries[:n:n], log.Entries[n+1:]...)
+			err := c.f.writeJSONFile(c.file(manLog+Extension), log)
+			if err != nil {
+				return err
+			}
+			delete(want, stamp)
+			return c.rename(name, manCurrent+Extension)
+		}, "the save at %v was lost, the version from before it was put back", stamp)
+	}
+	if err != nil {
+		return err
+	}
+
+	for stamp := range want {
+		if !c.exists(stamp + Extension) {
+			err := c.problem(nil, "the revision file %v is missing", stamp+Extension)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkCurrent checks the current version can be read, fixing it if there's something after the end of it
+func (c *checker) checkCurrent() error {
+	b, err := c.readAll(manCurrent + Extension)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	var v json.RawMessage
+	err = d.Decode(&v)
+	if err != nil {
+		return c.problem(nil, "the current version is damaged: %v", err)
+	}
+
+	end := d.InputOffset()
+	if len(bytes.TrimSpace(b[end:])) == 0 {
+		return nil
+	}
+	return c.problem(func() error {
+		return c.f.writeFile(c.file(manCurrent+Extension), func(w io.Writer) error {
+			_, err := w.Write(append(b[:end:end], '\n'))
+			return err
+		})
+	}, "the current version has leftovers from an older one after it")
+}
+
+// parseStamp parses the name of a revision file, which is in either TimeFormat or oldTimeFormat
+func parseStamp(s string) (time.Time, bool) {
+	for _, x := range []string{TimeFormat, oldTimeFormat} {
+		if at, err := time.Parse(x, s); err == nil {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (c *checker) readAll(name string) ([]byte, error) {
+	d, err := c.f.FS.Open(c.file(name))
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	return ioutil.ReadAll(d)
+}
+
+func (c *checker) rename(from, to string) error {
+	err := c.f.FS.Rename(c.file(from), c.file(to))
+	if err != nil {
+		return err
+	}
+	return c.f.sync(c.dir)
+}
+
+// live works out from the log whether there should be a current version
+func (c *checker) live(log wikithing.LogFile) bool {
+	live := false
+	for _, x := range log.Entries {
+		switch {
+		case ChangesContent(x.Action), x.Action == wikithing.LogActionRestore:
+			live = true
+		case x.Action == wikithing.LogActionDelete:
+			live = false
+		case x.Action == wikithing.LogActionMove:
+			_, to := wikithing.ParseMoveRef(x.Ref)
+			live = to.Equal(c.p)
+		}
+	}
+	return live
+}
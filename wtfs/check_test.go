@@ -0,0 +1,66 @@
+package wtfs
+
+import (
+	"testing"
+	"time"
+
+	"git.lan/wikithing"
+)
+
+func TestCheckOldRevisions(t *testing.T) {
+	f := NewMemory()
+	loc := wikithing.ParsePath("some/old/page")
+	oldPage(t, f, loc)
+
+	// and a newer save next to the old ones
+	base, err := f.PageStamp(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.SavePage(loc, article("third"), base, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps, err := f.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range ps {
+		t.Error(x)
+	}
+}
+
+func TestCheckOldLostSave(t *testing.T) {
+	f := NewMemory()
+	loc := wikithing.ParsePath("some/old/page")
+	_, second := oldPage(t, f, loc)
+	third := second.Add(time.Hour)
+
+	// saves used to move the current version to its revision file and log the save before writing the new one,
+	// stopping in between loses the current version
+	err := f.FS.Rename(pathCurrent(loc, Pages), pathSub(loc, Pages, third.Format(oldTimeFormat)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.appendLog(loc, Pages, wikithing.LogEntry{Action: wikithing.LogActionEdit, When: third})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps, err := f.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 || !ps[0].Repaired {
+		t.Fatalf("expected the lost save to be repaired, got %v", ps)
+	}
+
+	a, err := f.LoadPage(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Pages[0].Body != "second" {
+		t.Errorf("the current version is %q, not %q", a.Pages[0].Body, "second")
+	}
+}
@@ -1,14 +1,91 @@
 package wtfs
 
-import "git.lan/wikithing"
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+)
 
 // Media is the folder Media metadata goes in
 const Media = "media"
 
 func (f *Filesystem) LoadMedia(loc wikithing.Path) (a wikithing.FileObject, err error) {
-	return a, f.loadFile(loc, Media, &a.FileConfig)
+	return a, f.loadFile(loc, Media, &a)
 }
 
 func (f *Filesystem) SaveMedia(loc wikithing.Path, media wikithing.FileObject, why wikithing.LogEntry) error {
-	return f.updateFile(loc, Media, why, media)
+	err := f.updateFile(loc, Media, why, media)
+	if err != nil {
+		return err
+	}
+	f.media.set(media.ID, loc)
+	return nil
+}
+
+var errStopWalk = errors.New("stop walking")
+
+// mediaIndex keeps where media is by its ID so embedding it doesn't mean going through all of it,
+// it's built the first time it's needed and kept up to date with the media saved through the filesystem.
+// Entries are checked when they're used so ones for media that's gone or changed since don't matter
+type mediaIndex struct {
+	mu  sync.Mutex
+	ids map[sid.ID]wikithing.Path
+}
+
+func (m *mediaIndex) set(id sid.ID, loc wikithing.Path) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ids != nil {
+		m.ids[id] = loc
+	}
+}
+
+// MediaByID finds the media with the given ID
+func (f *Filesystem) MediaByID(id sid.ID) (loc wikithing.Path, a wikithing.FileObject, err error) {
+	f.media.mu.Lock()
+	if f.media.ids == nil {
+		err = f.indexMedia()
+	}
+	loc, ok := f.media.ids[id]
+	f.media.mu.Unlock()
+	if err != nil {
+		return loc, a, err
+	}
+	if !ok {
+		return loc, a, os.ErrNotExist
+	}
+
+	a, err = f.LoadMedia(loc)
+	if err == nil && a.ID != id {
+		err = os.ErrNotExist
+	}
+	if os.IsNotExist(err) {
+		f.media.mu.Lock()
+		if f.media.ids[id].Equal(loc) {
+			delete(f.media.ids, id)
+		}
+		f.media.mu.Unlock()
+	}
+	return loc, a, err
+}
+
+// indexMedia goes through all of the media to build the index, the index's lock should already be held
+func (f *Filesystem) indexMedia() error {
+	ids := map[sid.ID]wikithing.Path{}
+	err := f.walk(Media, func(p wikithing.Path) error {
+		o, err := f.LoadMedia(p)
+		if err != nil {
+			return err
+		}
+		ids[o.ID] = p
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	f.media.ids = ids
+	return nil
 }
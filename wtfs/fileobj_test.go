@@ -0,0 +1,67 @@
+package wtfs
+
+import (
+	"os"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+)
+
+func TestMediaByID(t *testing.T) {
+	f := NewMemory()
+	loc := wikithing.ParsePath("pictures/cat")
+	o := wikithing.FileObject{ID: sid.Get(), FileConfig: wikithing.FileConfig{Class: wikithing.FileClassImage, Hash: "abc"}}
+
+	find := func() error {
+		t.Helper()
+		p, got, err := f.MediaByID(o.ID)
+		if err == nil && (!p.Equal(loc) || got.Hash != o.Hash) {
+			t.Errorf("found %v %v instead of %v %v", p, got, loc, o)
+		}
+		return err
+	}
+
+	// media saved before the index is built is found by building it
+	err := f.SaveMedia(loc, o, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := find(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.MediaByID(sid.Get()); !os.IsNotExist(err) {
+		t.Errorf("looking for media that doesn't exist gave %v", err)
+	}
+
+	// and media saved after it's built is added to it
+	loc = wikithing.ParsePath("pictures/dog")
+	o = wikithing.FileObject{ID: sid.Get(), FileConfig: wikithing.FileConfig{Class: wikithing.FileClassImage, Hash: "def"}}
+	err = f.SaveMedia(loc, o, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := find(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.DeleteMedia(loc, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := find(); !os.IsNotExist(err) {
+		t.Errorf("looking for deleted media gave %v", err)
+	}
+
+	l, err := f.DeletedMedia()
+	if err != nil || len(l) != 1 {
+		t.Fatalf("got %v %v from the trash", l, err)
+	}
+	err = f.RestoreMedia(loc, l[0].Stamp, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := find(); err != nil {
+		t.Errorf("looking for restored media gave %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package wtfs
+
+import (
+	"os"
+	"time"
+
+	"git.lan/wikithing"
+)
+
+// Revision is a single version of a managed file along with the log entry that made it
+type Revision struct {
+	wikithing.LogEntry
+
+	// Stamp is when the revision was made in TimeFormat, it's how revisions are referred to
+	Stamp string
+	// Current is whether this is the version in use right now
+	Current bool
+}
+
+// contentActions are the log actions that leave behind a new version of a file
+var contentActions = map[uint]bool{
+	wikithing.LogActionCreate: true,
+	wikithing.LogActionEdit:   true,
+	wikithing.LogActionRevert: true,
+}
+
+// ChangesContent reports whether a log action made a new revision
+func ChangesContent(action uint) bool { return contentActions[action] }
+
+// PageLog loads the full log of a page
+func (f *Filesystem) PageLog(loc wikithing.Path) (wikithing.LogFile, error) {
+	return f.loadLog(loc, Pages)
+}
+
+// PageRevisions lists every revision of a page, oldest first
+func (f *Filesystem) PageRevisions(loc wikithing.Path) ([]Revision, error) {
+	return f.revisions(loc, Pages)
+}
+
+// LoadPageRevision loads the version of a page made at a specific time
+func (f *Filesystem) LoadPageRevision(loc wikithing.Path, at time.Time) (a wikithing.Article, err error) {
+	return a, f.loadRevision(loc, Pages, at, &a)
+}
+
+// RevertPage restores an old revision of a page as a new revision
+func (f *Filesystem) RevertPage(loc wikithing.Path, at time.Time, why wikithing.LogEntry) error {
+	a := new(wikithing.Article)
+	err := f.revert(loc, Pages, at, why, a)
+	if err != nil {
+		return err
+	}
+	f.PageChanged(loc, *a)
+	return nil
+}
+
+// LogPageAction adds an entry to the log of a page without changing the page, for things like protecting it
+func (f *Filesystem) LogPageAction(loc wikithing.Path, why wikithing.LogEntry) error {
+	return f.logAction(loc, Pages, why)
+}
+
+func (f *Filesystem) logAction(p wikithing.Path, pre string, why wikithing.LogEntry) error {
+	if _, err := f.FS.Stat(pathLog(p, pre)); err != nil {
+		return err
+	}
+
+	unlock, err := f.getLock(p, pre)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	why.When = time.Now().UTC()
+	return f.appendLog(p, pre, why)
+}
+
+func (f *Filesystem) revert(p wikithing.Path, pre string, at time.Time, why wikithing.LogEntry, dat interface{}) error {
+	err := f.loadRevision(p, pre, at, dat)
+	if err != nil {
+		return err
+	}
+
+	why.Ref = at.UTC().Format(TimeFormat)
+	return f.updateFileAs(p, pre, wikithing.LogActionRevert, why, dat)
+}
+
+func (f *Filesystem) loadLog(p wikithing.Path, pre string) (l wikithing.LogFile, err error) {
+	if _, err := f.FS.Stat(pathLog(p, pre)); err != nil {
+		return l, err
+	}
+	return l, f.loadJSON(p, pre, manLog, &l)
+}
+
+func (f *Filesystem) revisions(p wikithing.Path, pre string) ([]Revision, error) {
+	l, err := f.loadLog(p, pre)
+	if err != nil {
+		return nil, err
+	}
+
+	revs := make([]Revision, 0, len(l.Entries))
+	for _, x := range l.Entries {
+		if !ChangesContent(x.Action) {
+			continue
+		}
+		revs = append(revs, Revision{
+			LogEntry: x,
+			Stamp:    x.When.Format(TimeFormat),
+		})
+	}
+
+	if len(revs) > 0 {
+		if _, err := f.FS.Stat(pathCurrent(p, pre)); err == nil {
+			revs[len(revs)-1].Current = true
+		}
+	}
+
+	return revs, nil
+}
+
+// revisionFile works out the name a revision is stored under
+//
+// when a file is updated the old current version gets renamed to the time of the update,
+// so a revision is stored under the stamp of the revision after it, or as the current version if it's the latest
+func (f *Filesystem) revisionFile(p wikithing.Path, pre string, at time.Time) (string, error) {
+	revs, err := f.revisions(p, pre)
+	if err != nil {
+		return "", err
+	}
+
+	stamp := at.UTC().Format(TimeFormat)
+	for i, x := range revs {
+		if x.Stamp != stamp {
+			continue
+		}
+		if i+1 < len(revs) {
+			return f.whenName(p, pre, revs[i+1].When), nil
+		}
+		if x.Current {
+			return manCurrent, nil
+		}
+	}
+
+	return "", os.ErrNotExist
+}
+
+func (f *Filesystem) loadRevision(p wikithing.Path, pre string, at time.Time, dat interface{}) error {
+	sub, err := f.revisionFile(p, pre, at)
+	if err != nil {
+		return err
+	}
+	if _, err := f.FS.Stat(pathSub(p, pre, sub)); err != nil {
+		return err
+	}
+
+	return f.loadJSON(p, pre, sub, dat)
+}
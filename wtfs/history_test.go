@@ -0,0 +1,68 @@
+package wtfs
+
+import (
+	"testing"
+	"time"
+
+	"git.lan/wikithing"
+)
+
+func article(body string) wikithing.Article {
+	return wikithing.Article{Pages: []wikithing.Page{{Title: "Old", Body: body, Format: wikithing.FormatMarkdown}}}
+}
+
+// oldPage lays out a page the way it was saved before TimeFormat went down to the nanosecond,
+// with the first revision kept under the second one's stamp to the second
+func oldPage(t *testing.T, f *Filesystem, loc wikithing.Path) (first, second time.Time) {
+	first = time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.UTC)
+	second = first.Add(time.Hour + 987654321)
+
+	log := wikithing.LogFile{Entries: []wikithing.LogEntry{
+		{Action: wikithing.LogActionCreate, When: first},
+		{Action: wikithing.LogActionEdit, When: second},
+	}}
+	for name, v := range map[string]interface{}{
+		manLog:                       log,
+		manCurrent:                   article("second"),
+		second.Format(oldTimeFormat): article("first"),
+	} {
+		if err := f.writeJSON(loc, Pages, name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return first, second
+}
+
+func TestLoadOldRevision(t *testing.T) {
+	f := NewMemory()
+	loc := wikithing.ParsePath("some/old/page")
+	first, second := oldPage(t, f, loc)
+
+	for at, want := range map[time.Time]string{first: "first", second: "second"} {
+		a, err := f.LoadPageRevision(loc, at)
+		if err != nil {
+			t.Fatalf("loading the revision from %v: %v", at, err)
+		}
+		if a.Pages[0].Body != want {
+			t.Errorf("the revision from %v is %q, not %q", at, a.Pages[0].Body, want)
+		}
+	}
+
+	err := f.RevertPage(loc, first, wikithing.LogEntry{Reason: "back"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := f.LoadPage(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Pages[0].Body != "first" {
+		t.Errorf("reverting gave %q, not %q", a.Pages[0].Body, "first")
+	}
+
+	// the reverted revision is now kept under the new format alongside the old one
+	a, err = f.LoadPageRevision(loc, second)
+	if err != nil || a.Pages[0].Body != "second" {
+		t.Errorf("the revision from %v is %q after reverting (%v)", second, a.Pages[0].Body, err)
+	}
+}
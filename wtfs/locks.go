@@ -0,0 +1,232 @@
+package wtfs
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+)
+
+// Locking
+//
+// Anything that changes a managed file takes its lock once, at the start of the exported method doing the change,
+// and everything it calls while holding it is a version that expects the lock to already be held (writeJSON, updateFileLocked and so on).
+// Locks aren't reentrant, taking one that's already held waits for it like any other lock, so nothing should take a lock from under another.
+// Reading doesn't need the lock since files are only ever replaced whole (see writeFile)
+
+// LockOptions controls what happens when a lock is already taken
+type LockOptions struct {
+	// Timeout is how long to keep trying to get a lock before giving up with ErrLocked, zero gives up straight away
+	Timeout time.Duration
+	// Stale is how old a lock can get before it's assumed to be left over from something that stopped without letting go of it
+	// and is broken, zero only breaks locks from processes on the same host that aren't running anymore
+	Stale time.Duration
+}
+
+// DefaultLockOptions are the LockOptions filesystems start with
+var DefaultLockOptions = LockOptions{
+	Timeout: 5 * time.Second,
+	Stale:   2 * time.Minute,
+}
+
+// how long to wait between tries for a lock, doubling each time
+const (
+	lockWaitMin = 5 * time.Millisecond
+	lockWaitMax = 250 * time.Millisecond
+)
+
+// ErrLocked is given when a lock couldn't be had in time
+var ErrLocked = wterr.New(wterr.ErrConflict, "someone else is changing this right now, try again in a moment")
+
+// Lock is what is kept in a lockfile, who has it and since when
+type Lock struct {
+	// File is where the lockfile is in the filesystem
+	File string `json:"-"`
+
+	PID  int
+	Host string
+	Time time.Time
+
+	// Stale is whether the lock would be broken by the next thing wanting it
+	Stale bool `json:"-"`
+}
+
+// same checks if two locks are the same one
+func (l Lock) same(o Lock) bool { return l.PID == o.PID && l.Host == o.Host && l.Time.Equal(o.Time) }
+
+var hostname, _ = os.Hostname()
+
+// getLock fetches the lock file for a managed data group preventing other concurrent processes from also modifying it,
+// waiting for it if something else has it
+func (f *Filesystem) getLock(p wikithing.Path, prefix string) (func(), error) {
+	file := pathLock(p, prefix)
+	deadline := time.Now().Add(f.Locking.Timeout)
+	wait := lockWaitMin
+
+	for {
+		unlock, err := f.tryLock(file)
+		if !os.IsExist(err) {
+			return unlock, err
+		}
+		if f.breakStale(file) {
+			continue
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(wait)
+		if wait *= 2; wait > lockWaitMax {
+			wait = lockWaitMax
+		}
+	}
+}
+
+// tryLock takes a lock if nothing else has it, failing with os.ErrExist if something does
+func (f *Filesystem) tryLock(file string) (func(), error) {
+	l, err := f.FS.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	me := Lock{PID: os.Getpid(), Host: hostname, Time: time.Now().UTC()}
+	err = json.NewEncoder(l).Encode(me)
+	if cerr := l.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		f.FS.Remove(file)
+		return nil, err
+	}
+
+	return func() {
+		// if it was broken while it was held then the lock there now belongs to something else
+		if cur, err := f.readLock(file); err == nil && !cur.same(me) {
+			log.Println("wtfs: the lock", file, "was broken while it was held")
+			return
+		}
+		f.FS.Remove(file)
+	}, nil
+}
+
+func (f *Filesystem) readLock(file string) (l Lock, err error) {
+	l.File = file
+	err = f.readJSON(file, &l)
+	if err != nil {
+		return l, err
+	}
+	l.Stale = f.isStale(l)
+	return l, nil
+}
+
+// isStale checks if a lock has been left behind by something that's not going to let go of it
+func (f *Filesystem) isStale(l Lock) bool {
+	if l.Host == hostname && l.PID != os.Getpid() && !processRunning(l.PID) {
+		return true
+	}
+	return f.Locking.Stale > 0 && time.Since(l.Time) > f.Locking.Stale
+}
+
+// breakStale removes a lock if it's stale, reporting whether it's worth trying to take it again straight away
+func (f *Filesystem) breakStale(file string) bool {
+	l, err := f.readLock(file)
+	switch {
+	case os.IsNotExist(err):
+		return true
+	case err != nil:
+		// it's either being written right now or whatever was writing it stopped part way through
+		i, serr := f.FS.Stat(file)
+		if serr != nil {
+			return os.IsNotExist(serr)
+		}
+		if time.Since(i.ModTime()) < lockWaitMax*4 {
+			return false
+		}
+	case !l.Stale:
+		return false
+	}
+
+	// it's moved out of the way first so if two things break the same lock at once only one of them gets it,
+	// and if it turns out something else took the lock in the meantime then it's put back
+	broken := file + ".broken"
+	if err := f.FS.Rename(file, broken); err != nil {
+		return os.IsNotExist(err)
+	}
+	if got, err := f.readLock(broken); err == nil && l.Host != "" && !got.same(l) {
+		f.FS.Rename(broken, file)
+		return false
+	}
+	f.FS.Remove(broken)
+
+	log.Printf("wtfs: broke the stale lock %v held by process %v on %v since %v", file, l.PID, l.Host, l.Time)
+	return true
+}
+
+// Locks lists every lock that's held
+func (f *Filesystem) Locks() ([]Lock, error) {
+	var out []Lock
+	for _, x := range []string{Pages, Media, Talk, Users, Config} {
+		err := f.findLocks(x, &out)
+		if err != nil {
+			return out, err
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+func (f *Filesystem) findLocks(dir string, out *[]Lock) error {
+	l, err := f.FS.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, x := range l {
+		name := path.Join(dir, x.Name())
+		if x.IsDir() {
+			if err := f.findLocks(name, out); err != nil {
+				return err
+			}
+			continue
+		}
+		if x.Name() != manLock+Extension {
+			continue
+		}
+
+		lk, err := f.readLock(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			// a lockfile that can't be read is as good as stale once it's not being written
+			lk = Lock{File: name, Time: x.ModTime(), Stale: time.Since(x.ModTime()) > lockWaitMax*4}
+		}
+		*out = append(*out, lk)
+	}
+	return nil
+}
+
+// ClearLock removes a lock no matter who has it, file is a Lock's File
+func (f *Filesystem) ClearLock(file string) error {
+	file = path.Clean("/" + file)[1:]
+	if path.Base(file) != manLock+Extension {
+		return wterr.Newf(wterr.ErrInvalidInput, "%v isn't a lockfile", file)
+	}
+	return f.FS.Remove(file)
+}
+
+// Locker is a Store that uses lockfiles, which admins might need to look at and clear
+type Locker interface {
+	Locks() ([]Lock, error)
+	ClearLock(file string) error
+}
+
+var _ Locker = (*Filesystem)(nil)
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package wtfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// processRunning checks if a process on this host is still running
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// signal 0 only checks whether the process is there, not being allowed to signal it still means it is
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
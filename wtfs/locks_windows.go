@@ -0,0 +1,13 @@
+package wtfs
+
+import "os"
+
+// processRunning checks if a process on this host is still running
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -2,13 +2,15 @@ package wtfs
 
 import (
 	"os"
+	"path"
 	"time"
 
 	"git.lan/wikithing"
 )
 
+// initialise sets up the directory and log for a new managed file, the lock should already be held
 func (f *Filesystem) initialise(p wikithing.Path, pre string) error {
-	err := f.FS.MkdirAll(p.Path(), 0664)
+	err := f.FS.MkdirAll(path.Join(pre, p.Path()), 0775)
 	if err != nil {
 		return err
 	}
@@ -17,17 +19,19 @@ func (f *Filesystem) initialise(p wikithing.Path, pre string) error {
 }
 
 func (f *Filesystem) initLog(p wikithing.Path, pre string) error {
-	return f.saveJSON(p, pre, manLog, wikithing.LogFile{})
+	return f.writeJSON(p, pre, manLog, wikithing.LogFile{})
 }
 
+// appendLog adds an entry to the log of a managed file, the lock should already be held
 func (f *Filesystem) appendLog(p wikithing.Path, pre string, le wikithing.LogEntry) error {
-	var log = new(wikithing.LogFile)
-
-	return f.atomicUpdateJSON(p, pre, manLog, log, func() error {
-		log.Entries = append(log.Entries, le)
+	var log wikithing.LogFile
+	err := f.readJSON(pathLog(p, pre), &log)
+	if err != nil {
+		return err
+	}
 
-		return nil
-	})
+	log.Entries = append(log.Entries, le)
+	return f.writeJSON(p, pre, manLog, log)
 }
 
 func (f *Filesystem) loadFile(p wikithing.Path, pre string, dat interface{}) error {
@@ -35,29 +39,99 @@ func (f *Filesystem) loadFile(p wikithing.Path, pre string, dat interface{}) err
 }
 
 func (f *Filesystem) updateFile(p wikithing.Path, pre string, log wikithing.LogEntry, dat interface{}) error {
+	return f.updateFileAs(p, pre, wikithing.LogActionUnspecified, log, dat)
+}
+
+// updateFileAs is updateFile but logged as a specific action, if the action is unspecified it's logged as a create or edit,
+// if the action is a create then it fails with os.ErrExist when there is already a file there
+func (f *Filesystem) updateFileAs(p wikithing.Path, pre string, action uint, log wikithing.LogEntry, dat interface{}) error {
+	unlock, err := f.getLock(p, pre)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return f.updateFileLocked(p, pre, action, log, dat)
+}
+
+// updateFileFrom is updateFile but only if the current revision is still base, otherwise it fails with ErrConflict.
+// base is empty if there shouldn't be a file there yet
+func (f *Filesystem) updateFileFrom(p wikithing.Path, pre, base string, log wikithing.LogEntry, dat interface{}) error {
 	unlock, err := f.getLock(p, pre)
 	if err != nil {
 		return err
 	}
 	defer unlock()
 
+	cur, err := f.currentStamp(p, pre)
+	if err != nil {
+		return err
+	}
+	if cur != base {
+		return ErrConflict
+	}
+
+	return f.updateFileLocked(p, pre, wikithing.LogActionUnspecified, log, dat)
+}
+
+// currentStamp gets the stamp of the current revision of a managed file without taking the lock, it's empty if there isn't one
+func (f *Filesystem) currentStamp(p wikithing.Path, pre string) (string, error) {
+	if _, err := f.FS.Stat(pathCurrent(p, pre)); os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	var l wikithing.LogFile
+	err := f.readJSON(pathLog(p, pre), &l)
+	if err != nil {
+		return "", err
+	}
+	for i := len(l.Entries) - 1; i >= 0; i-- {
+		if ChangesContent(l.Entries[i].Action) {
+			return l.Entries[i].When.Format(TimeFormat), nil
+		}
+	}
+	return "", nil
+}
+
+// updateFileLocked is updateFileAs for when the lock is already held
+func (f *Filesystem) updateFileLocked(p wikithing.Path, pre string, action uint, log wikithing.LogEntry, dat interface{}) (err error) {
 	when := time.Now().UTC()
 	log.When = when
 
 	if _, err := f.FS.Stat(pathSub(p, pre, manCurrent)); err != nil && os.IsNotExist(err) {
 		log.Action = wikithing.LogActionCreate
+
+		// pages that were created before keep their log around
+		if _, err := f.FS.Stat(pathLog(p, pre)); err != nil && os.IsNotExist(err) {
+			err = f.initialise(p, pre)
+			if err != nil {
+				return err
+			}
+		}
 	} else {
+		if action == wikithing.LogActionCreate {
+			return os.ErrExist
+		}
+
+		// the old version is copied rather than moved so there's always a current version,
+		// and the log entry goes in last, if saving stops part way through Check puts back the copy
 		log.Action = wikithing.LogActionEdit
-		err = f.moveFile(p, pre, manCurrent, when.Format(TimeFormat))
+		err = f.copyFile(pathCurrent(p, pre), pathWhen(p, pre, when))
 		if err != nil {
 			return err
 		}
 	}
 
-	err = f.appendLog(p, pre, log)
+	if action != wikithing.LogActionUnspecified {
+		log.Action = action
+	}
+
+	err = f.writeJSON(p, pre, manCurrent, dat)
 	if err != nil {
 		return err
 	}
 
-	return f.saveJSON(p, pre, manCurrent, dat)
+	return f.appendLog(p, pre, log)
 }
@@ -0,0 +1,154 @@
+package wtfs
+
+import (
+	"os"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// syncFS wraps a filesystem that isn't safe to use from multiple goroutines, like memfs, so that it is
+// by only letting one thing at a time into it or any of the files opened from it
+type syncFS struct {
+	mu *sync.Mutex
+	fs billy.Filesystem
+}
+
+func newSyncFS(fs billy.Filesystem) billy.Filesystem {
+	return &syncFS{mu: new(sync.Mutex), fs: fs}
+}
+
+func (s *syncFS) file(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &syncFile{mu: s.mu, f: f}, nil
+}
+
+func (s *syncFS) Create(name string) (billy.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file(s.fs.Create(name))
+}
+
+func (s *syncFS) Open(name string) (billy.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file(s.fs.Open(name))
+}
+
+func (s *syncFS) OpenFile(name string, flag int, perm os.FileMode) (billy.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file(s.fs.OpenFile(name, flag, perm))
+}
+
+func (s *syncFS) TempFile(dir, prefix string) (billy.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file(s.fs.TempFile(dir, prefix))
+}
+
+func (s *syncFS) Stat(name string) (os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs.Stat(name)
+}
+
+func (s *syncFS) Lstat(name string) (os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs.Lstat(name)
+}
+
+func (s *syncFS) Rename(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs.Rename(from, to)
+}
+
+func (s *syncFS) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs.Remove(name)
+}
+
+func (s *syncFS) ReadDir(name string) ([]os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs.ReadDir(name)
+}
+
+func (s *syncFS) MkdirAll(name string, perm os.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs.MkdirAll(name, perm)
+}
+
+func (s *syncFS) Symlink(target, link string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs.Symlink(target, link)
+}
+
+func (s *syncFS) Readlink(link string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs.Readlink(link)
+}
+
+func (s *syncFS) Join(elem ...string) string { return s.fs.Join(elem...) }
+func (s *syncFS) Root() string               { return s.fs.Root() }
+
+func (s *syncFS) Chroot(name string) (billy.Filesystem, error) {
+	return chroot.New(s, name), nil
+}
+
+// syncFile is a file opened from a syncFS, which shares its lock since files can share what they have in them
+type syncFile struct {
+	mu *sync.Mutex
+	f  billy.File
+}
+
+func (s *syncFile) Name() string { return s.f.Name() }
+
+func (s *syncFile) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Write(p)
+}
+
+func (s *syncFile) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Read(p)
+}
+
+func (s *syncFile) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.ReadAt(p, off)
+}
+
+func (s *syncFile) Seek(off int64, whence int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Seek(off, whence)
+}
+
+func (s *syncFile) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Truncate(size)
+}
+
+func (s *syncFile) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// Lock and Unlock are file locks, they'd wait while holding mu so they don't take it
+func (s *syncFile) Lock() error   { return s.f.Lock() }
+func (s *syncFile) Unlock() error { return s.f.Unlock() }
@@ -0,0 +1,129 @@
+package wtfs
+
+import (
+	"os"
+	"path"
+	"time"
+
+	"git.lan/wikithing"
+)
+
+// MovePage moves a page along with all of its history and its discussion to a new path,
+// only the page itself moves and anything under it stays where it is.
+// The move is logged at both ends, and if redirect is set a page redirecting to the new path is left behind.
+// It fails with os.ErrExist if there is already anything at the new path, even just the history of a page that moved away,
+// and with ErrTalkExists if there's a discussion there. If the discussion still can't be moved once the page has,
+// the move is finished anyway and TalkLeftBehind is given
+func (f *Filesystem) MovePage(from, to wikithing.Path, why wikithing.LogEntry, redirect bool) error {
+	err := f.CheckTalkMove(from, to)
+	if err != nil {
+		return err
+	}
+
+	err = f.move(from, to, Pages, why)
+	if err != nil {
+		return err
+	}
+
+	var talkErr error
+	if err := f.MoveTalk(from, to, why); err != nil {
+		talkErr = TalkLeftBehind(from, err)
+	}
+
+	f.PageRemoved(from)
+	if a, err := f.LoadPage(to); err == nil {
+		f.PageChanged(to, a)
+	}
+
+	if !redirect {
+		return talkErr
+	}
+
+	stub := wikithing.Article{Redirect: to.String()}
+	why.Reason = "Redirect to " + to.String()
+	err = f.updateFileAs(from, Pages, wikithing.LogActionCreate, why, stub)
+	if err != nil {
+		return err
+	}
+	f.PageChanged(from, stub)
+	return talkErr
+}
+
+func (f *Filesystem) move(from, to wikithing.Path, pre string, why wikithing.LogEntry) error {
+	if from.Equal(to) {
+		return os.ErrExist
+	}
+
+	err := f.moveLocked(from, to, pre, why)
+	if err != nil {
+		// taking the locks makes the directories if they weren't there,
+		// this fails if there's anything in them which is fine since then they still have a use
+		f.FS.Remove(path.Join(pre, from.Path()))
+		f.FS.Remove(path.Join(pre, to.Path()))
+	}
+	return err
+}
+
+// moveLocked takes the locks for both ends of a move before checking the move can happen and doing it,
+// so nothing can be saved or moved to either of them in between
+func (f *Filesystem) moveLocked(from, to wikithing.Path, pre string, why wikithing.LogEntry) error {
+	// the locks are always taken in the same order so two moves going opposite ways don't wait on each other
+	first, second := from, to
+	if to.String() < from.String() {
+		first, second = to, from
+	}
+	unlockFirst, err := f.getLock(first, pre)
+	if err != nil {
+		return err
+	}
+	defer unlockFirst()
+	unlockSecond, err := f.getLock(second, pre)
+	if err != nil {
+		return err
+	}
+	defer unlockSecond()
+
+	if _, err := f.FS.Stat(pathCurrent(from, pre)); err != nil {
+		return err
+	}
+	if _, err := f.FS.Stat(pathLog(to, pre)); err == nil {
+		return os.ErrExist
+	}
+
+	err = f.FS.MkdirAll(path.Join(pre, to.Path()), 0775)
+	if err != nil {
+		return err
+	}
+
+	dir := path.Join(pre, from.Path())
+	l, err := f.FS.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, x := range l {
+		// directories are pages under this one, which stay where they are
+		if x.IsDir() || x.Name() == manLock+Extension {
+			continue
+		}
+		err = f.FS.Rename(path.Join(dir, x.Name()), path.Join(pre, to.Path(), x.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	why.When = time.Now().UTC()
+	why.Action = wikithing.LogActionMove
+	why.Ref = wikithing.MoveRef(from, to)
+
+	err = f.appendLog(to, pre, why)
+	if err != nil {
+		return err
+	}
+
+	// the old path gets a fresh log so there is a record of where the page went
+	err = f.initLog(from, pre)
+	if err != nil {
+		return err
+	}
+	return f.appendLog(from, pre, why)
+}
@@ -0,0 +1,166 @@
+package wtfs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"git.lan/wikithing"
+)
+
+func TestMoveMissing(t *testing.T) {
+	f := NewMemory()
+	from, to := wikithing.ParsePath("not/here"), wikithing.ParsePath("somewhere")
+
+	err := f.MovePage(from, to, wikithing.LogEntry{}, false)
+	if !os.IsNotExist(err) {
+		t.Fatalf("moving a page that doesn't exist gave %v", err)
+	}
+	for _, x := range []wikithing.Path{from, to} {
+		if _, err := f.FS.Stat(path.Join(Pages, x.Path())); !os.IsNotExist(err) {
+			t.Errorf("%v was left behind (%v)", x, err)
+		}
+	}
+}
+
+// moves to the same place at the same time shouldn't both go ahead
+func TestMoveSameTarget(t *testing.T) {
+	f := NewMemory()
+	to := wikithing.ParsePath("target")
+
+	const n = 8
+	for i := 0; i < n; i++ {
+		err := f.SavePage(wikithing.ParsePath(fmt.Sprint("page/", i)), article(fmt.Sprint(i)), "", wikithing.LogEntry{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// something is in the middle of changing the target while the moves start
+	unlock, err := f.getLock(to, Pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = f.MovePage(wikithing.ParsePath(fmt.Sprint("page/", i)), to, wikithing.LogEntry{}, false)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	unlock()
+	wg.Wait()
+
+	moved := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			moved++
+		case !os.IsExist(err):
+			t.Errorf("moving page %v gave %v", i, err)
+		}
+	}
+	if moved != 1 {
+		t.Fatalf("%v pages were moved to the same place", moved)
+	}
+
+	l, err := f.PageLog(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 2 {
+		t.Errorf("the moved page has %v log entries, not 2", len(l.Entries))
+	}
+}
+
+func talk(t *testing.T, f *Filesystem, loc wikithing.Path, body string) {
+	t.Helper()
+	err := f.UpdateTalk(loc, wikithing.LogEntry{}, func(d *wikithing.Discussion) error {
+		d.Comments = append(d.Comments, wikithing.Comment{Body: body})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// a discussion at the new path stops the move before anything happens
+func TestMoveOntoTalk(t *testing.T) {
+	f := NewMemory()
+	from, to := wikithing.ParsePath("from"), wikithing.ParsePath("to")
+
+	err := f.SavePage(from, article("page"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	talk(t, f, from, "about the page")
+	talk(t, f, to, "already here")
+
+	err = f.MovePage(from, to, wikithing.LogEntry{}, true)
+	if err != ErrTalkExists {
+		t.Fatalf("moving onto a discussion gave %v", err)
+	}
+	if _, err := f.LoadPage(from); err != nil {
+		t.Errorf("the page moved anyway: %v", err)
+	}
+	if _, err := f.LoadPage(to); !os.IsNotExist(err) {
+		t.Errorf("the page is at the new path too: %v", err)
+	}
+}
+
+// a discussion turning up at the new path part way through still lets the page finish moving
+func TestMoveTalkLeftBehind(t *testing.T) {
+	f := NewMemory()
+	from, to := wikithing.ParsePath("from"), wikithing.ParsePath("to")
+
+	err := f.SavePage(from, article("page"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	talk(t, f, from, "about the page")
+
+	var removed, changed []string
+	f.OnPageRemove(func(loc wikithing.Path) { removed = append(removed, loc.String()) })
+	f.OnPageChange(func(loc wikithing.Path, a wikithing.Article) { changed = append(changed, loc.String()) })
+
+	// the discussion at the new path gets started while the move is waiting for its lock
+	unlock, err := f.getLock(to, Talk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- f.MovePage(from, to, wikithing.LogEntry{}, true)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	err = f.initLog(to, Talk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	err = <-done
+
+	if err == nil || os.IsExist(err) || err == ErrTalkExists {
+		t.Fatalf("moving the page gave %v", err)
+	}
+	if _, err := f.LoadPage(to); err != nil {
+		t.Errorf("the page didn't move: %v", err)
+	}
+	a, err := f.LoadPage(from)
+	if err != nil || a.Redirect != to.String() {
+		t.Errorf("no redirect was left behind: %v %v", a, err)
+	}
+	if _, err := f.LoadTalk(from); err != nil {
+		t.Errorf("the discussion didn't stay where it was: %v", err)
+	}
+	if fmt.Sprint(removed) != "[from]" || fmt.Sprint(changed) != "[to from]" {
+		t.Errorf("the hooks ran for removed %v changed %v", removed, changed)
+	}
+}
@@ -1,16 +1,89 @@
 package wtfs
 
 import (
+	"errors"
+	"os"
+
 	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
 )
 
 // Pages is the folder Pages go in
 const Pages = "pages"
 
+// Errors from following redirects
+var (
+	ErrRedirectLoop     = errors.New("redirect loop")
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// ErrConflict is given when saving a page that someone else changed since the save started from it
+var ErrConflict = wterr.New(wterr.ErrConflict, "the page was changed by someone else while you were editing it")
+
+// FollowRedirects follows the redirects starting at a page, giving back every path along the way,
+// the last one is where it ends up which might not exist
+func (f *Filesystem) FollowRedirects(loc wikithing.Path) ([]wikithing.Path, error) {
+	return ResolveRedirects(f.LoadPage, loc)
+}
+
+// ResolveRedirects is FollowRedirects for any way of loading pages
+func ResolveRedirects(load func(wikithing.Path) (wikithing.Article, error), loc wikithing.Path) ([]wikithing.Path, error) {
+	chain := []wikithing.Path{loc}
+	for {
+		a, err := load(loc)
+		if os.IsNotExist(err) {
+			return chain, nil
+		}
+		if err != nil {
+			return chain, err
+		}
+
+		t, ok := a.RedirectTarget()
+		if !ok {
+			return chain, nil
+		}
+		for _, x := range chain {
+			if x.Equal(t) {
+				return chain, ErrRedirectLoop
+			}
+		}
+		if len(chain) > wikithing.MaxRedirects {
+			return chain, ErrTooManyRedirects
+		}
+
+		chain = append(chain, t)
+		loc = t
+	}
+}
+
 func (f *Filesystem) LoadPage(loc wikithing.Path) (a wikithing.Article, err error) {
 	return a, f.loadFile(loc, Pages, &a)
 }
 
-func (f *Filesystem) SavePage(loc wikithing.Path, page wikithing.Article, why wikithing.LogEntry) error {
-	return f.updateFile(loc, Pages, why, page)
+// PageStamp gets the stamp of the current revision of a page, which is what saves start from.
+// It's empty if the page doesn't exist
+func (f *Filesystem) PageStamp(loc wikithing.Path) (string, error) {
+	return f.currentStamp(loc, Pages)
+}
+
+// SavePage saves a new revision of a page, base is the stamp of the revision the changes were made to
+// (or empty for a new page) and if the page has changed since then it fails with ErrConflict
+func (f *Filesystem) SavePage(loc wikithing.Path, page wikithing.Article, base string, why wikithing.LogEntry) error {
+	err := f.updateFileFrom(loc, Pages, base, why, page)
+	if err != nil {
+		return err
+	}
+	f.PageChanged(loc, page)
+	return nil
+}
+
+// WalkPages calls fn with every page, parents before their children
+func (f *Filesystem) WalkPages(fn func(loc wikithing.Path, a wikithing.Article) error) error {
+	return f.walk(Pages, func(p wikithing.Path) error {
+		a, err := f.LoadPage(p)
+		if err != nil {
+			return err
+		}
+		return fn(p, a)
+	})
 }
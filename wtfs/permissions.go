@@ -0,0 +1,25 @@
+package wtfs
+
+import (
+	"os"
+
+	"git.lan/wikithing"
+)
+
+// Config is the folder for settings for the wiki itself
+const Config = "config"
+
+var permissionsPath = wikithing.ParsePath("permissions")
+
+// LoadPermissions loads the permission rules, if none have been saved yet then there are no restrictions
+func (f *Filesystem) LoadPermissions() (ps wikithing.Permissions, err error) {
+	err = f.loadFile(permissionsPath, Config, &ps)
+	if os.IsNotExist(err) {
+		return ps, nil
+	}
+	return ps, err
+}
+
+func (f *Filesystem) SavePermissions(ps wikithing.Permissions, why wikithing.LogEntry) error {
+	return f.updateFile(permissionsPath, Config, why, ps)
+}
@@ -0,0 +1,59 @@
+package wtfs
+
+import (
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+)
+
+// Store is everything the rest of the wiki needs from wherever its data is kept.
+// Filesystem is the implementation of it, over either files on disk or memory.
+//
+// Anything that isn't there gives an error that os.IsNotExist recognises, and paths that are taken give one os.IsExist does
+type Store interface {
+	LoadPage(loc wikithing.Path) (wikithing.Article, error)
+	// SavePage saves a page if it hasn't changed since the revision base, otherwise it fails with ErrConflict
+	SavePage(loc wikithing.Path, a wikithing.Article, base string, why wikithing.LogEntry) error
+	PageStamp(loc wikithing.Path) (string, error)
+	FollowRedirects(loc wikithing.Path) ([]wikithing.Path, error)
+	WalkPages(fn func(loc wikithing.Path, a wikithing.Article) error) error
+	ListChildren(loc wikithing.Path) ([]Child, error)
+	MovePage(from, to wikithing.Path, why wikithing.LogEntry, redirect bool) error
+
+	PageLog(loc wikithing.Path) (wikithing.LogFile, error)
+	PageRevisions(loc wikithing.Path) ([]Revision, error)
+	LoadPageRevision(loc wikithing.Path, at time.Time) (wikithing.Article, error)
+	RevertPage(loc wikithing.Path, at time.Time, why wikithing.LogEntry) error
+	LogPageAction(loc wikithing.Path, why wikithing.LogEntry) error
+
+	DeletePage(loc wikithing.Path, why wikithing.LogEntry) error
+	RestorePage(loc wikithing.Path, stamp string, why wikithing.LogEntry) error
+	DeletedPages() ([]Deleted, error)
+
+	LoadMedia(loc wikithing.Path) (wikithing.FileObject, error)
+	SaveMedia(loc wikithing.Path, media wikithing.FileObject, why wikithing.LogEntry) error
+	MediaByID(id sid.ID) (wikithing.Path, wikithing.FileObject, error)
+	DeleteMedia(loc wikithing.Path, why wikithing.LogEntry) error
+	RestoreMedia(loc wikithing.Path, stamp string, why wikithing.LogEntry) error
+	DeletedMedia() ([]Deleted, error)
+
+	LoadTalk(loc wikithing.Path) (wikithing.Discussion, error)
+	TalkLog(loc wikithing.Path) (wikithing.LogFile, error)
+	UpdateTalk(loc wikithing.Path, why wikithing.LogEntry, fn func(d *wikithing.Discussion) error) error
+
+	LoadUser(name string) (wikithing.User, error)
+	SaveUser(u wikithing.User, why wikithing.LogEntry) error
+	CreateUser(u wikithing.User, why wikithing.LogEntry) error
+	UserByID(id sid.ID) (wikithing.User, error)
+	ListUsers() ([]wikithing.User, error)
+
+	LoadPermissions() (wikithing.Permissions, error)
+	SavePermissions(ps wikithing.Permissions, why wikithing.LogEntry) error
+
+	// OnPageChange and OnPageRemove add hooks for keeping things like search indexes up to date
+	OnPageChange(fn PageHook)
+	OnPageRemove(fn func(loc wikithing.Path))
+}
+
+var _ Store = (*Filesystem)(nil)
@@ -0,0 +1,104 @@
+package wtfs
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+)
+
+// Talk is the folder the discussions about pages go in, each page's discussion has its own log separate from the page
+const Talk = "talk"
+
+// LoadTalk loads the discussion of a page
+func (f *Filesystem) LoadTalk(loc wikithing.Path) (d wikithing.Discussion, err error) {
+	return d, f.loadFile(loc, Talk, &d)
+}
+
+// TalkLog loads the full log of the discussion of a page
+func (f *Filesystem) TalkLog(loc wikithing.Path) (wikithing.LogFile, error) {
+	return f.loadLog(loc, Talk)
+}
+
+// ErrTalkExists is given when a page can't take its discussion with it when it moves because there's one at the new path already
+var ErrTalkExists = wterr.New(wterr.ErrConflict, "there is already a discussion at the new path")
+
+// CheckTalkMove checks the discussion of a page can go along with it to a new path, failing with ErrTalkExists if it can't
+func (f *Filesystem) CheckTalkMove(from, to wikithing.Path) error {
+	if _, err := f.FS.Stat(pathCurrent(from, Talk)); err != nil {
+		return nil
+	}
+	if _, err := f.FS.Stat(pathLog(to, Talk)); err == nil {
+		return ErrTalkExists
+	}
+	return nil
+}
+
+// MoveTalk moves the discussion of a page along with it, failing with ErrTalkExists if there's one at the new path already.
+// Pages that have never been discussed are fine
+func (f *Filesystem) MoveTalk(from, to wikithing.Path, why wikithing.LogEntry) error {
+	if _, err := f.FS.Stat(pathCurrent(from, Talk)); err != nil {
+		return nil
+	}
+	err := f.move(from, to, Talk, why)
+	if os.IsExist(err) {
+		return ErrTalkExists
+	}
+	return err
+}
+
+// TalkLeftBehind is given when a page has been moved but its discussion couldn't go with it
+func TalkLeftBehind(from wikithing.Path, err error) error {
+	log.Println("wtfs: the discussion of", from, "couldn't be moved:", err)
+	return wterr.Newf(wterr.ErrConflict, "the page was moved but its discussion was left behind at %v", from)
+}
+
+// DeleteTalk moves the discussion of a page into the trash when the page is deleted, at is when the page was deleted
+// so they're both kept under the same stamp and come back together. Pages that have never been discussed are fine
+func (f *Filesystem) DeleteTalk(loc wikithing.Path, why wikithing.LogEntry, at time.Time) error {
+	if _, err := f.FS.Stat(pathCurrent(loc, Talk)); err != nil {
+		return nil
+	}
+	return f.delete(loc, Talk, why, at)
+}
+
+// RestoreTalk brings back the discussion that went into the trash with a page,
+// unless it didn't have one or there's another one at its path now, which it stays in the trash for
+func (f *Filesystem) RestoreTalk(loc wikithing.Path, stamp string, why wikithing.LogEntry) error {
+	if err := CheckStamp(stamp); err != nil {
+		return err
+	}
+	if _, err := f.FS.Stat(trashDir(loc, Talk, stamp)); err != nil {
+		return nil
+	}
+	err := f.restore(loc, Talk, stamp, why)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// UpdateTalk changes the discussion of a page, the lock is held the whole time so comments posted at the same time don't get lost.
+// fn gets an empty discussion if there isn't one yet, if it fails nothing is saved
+func (f *Filesystem) UpdateTalk(loc wikithing.Path, why wikithing.LogEntry, fn func(d *wikithing.Discussion) error) error {
+	unlock, err := f.getLock(loc, Talk)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	d := new(wikithing.Discussion)
+	err = f.loadFile(loc, Talk, d)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = fn(d)
+	if err != nil {
+		return err
+	}
+
+	return f.updateFileLocked(loc, Talk, wikithing.LogActionUnspecified, why, d)
+}
@@ -0,0 +1,241 @@
+package wtfs
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+)
+
+// Trash is the folder deleted things are kept in, under the name of the folder they came from
+const Trash = "trash"
+
+// Deleted is something in the trash
+type Deleted struct {
+	Path wikithing.Path
+	// Stamp tells apart different things that were deleted from the same path
+	Stamp string
+	// Entry is the log entry for deleting it
+	Entry wikithing.LogEntry
+}
+
+// ErrBadStamp is given for a stamp that nothing could have been deleted under
+var ErrBadStamp = wterr.New(wterr.ErrInvalidInput, "that isn't a time anything was deleted at")
+
+// CheckStamp makes sure a stamp is one something could have been deleted under, stamps come from outside so this is done before they go anywhere near a path
+func CheckStamp(stamp string) error {
+	if _, ok := parseStamp(stamp); !ok {
+		return ErrBadStamp
+	}
+	return nil
+}
+
+// trashDir is where something deleted from a path at a time goes,
+// the path is kept as one segment so nothing in the trash is nested in anything else
+func trashDir(p wikithing.Path, pre, stamp string) string {
+	return path.Join(Trash, pre, p.String()+"@"+stamp)
+}
+
+// DeletePage moves a page and its history into the trash along with its discussion, pages under it aren't touched
+func (f *Filesystem) DeletePage(loc wikithing.Path, why wikithing.LogEntry) error {
+	at := time.Now().UTC()
+	err := f.delete(loc, Pages, why, at)
+	if err != nil {
+		return err
+	}
+	f.PageRemoved(loc)
+
+	return f.DeleteTalk(loc, why, at)
+}
+
+// RestorePage brings a page back out of the trash, failing with os.ErrExist if something else is at its path now
+func (f *Filesystem) RestorePage(loc wikithing.Path, stamp string, why wikithing.LogEntry) error {
+	err := f.restore(loc, Pages, stamp, why)
+	if err != nil {
+		return err
+	}
+	if a, err := f.LoadPage(loc); err == nil {
+		f.PageChanged(loc, a)
+	}
+
+	return f.RestoreTalk(loc, stamp, why)
+}
+
+// DeletedPages lists every page in the trash, most recently deleted first
+func (f *Filesystem) DeletedPages() ([]Deleted, error) {
+	return f.listTrash(Pages)
+}
+
+func (f *Filesystem) DeleteMedia(loc wikithing.Path, why wikithing.LogEntry) error {
+	return f.delete(loc, Media, why, time.Now().UTC())
+}
+
+func (f *Filesystem) RestoreMedia(loc wikithing.Path, stamp string, why wikithing.LogEntry) error {
+	err := f.restore(loc, Media, stamp, why)
+	if err != nil {
+		return err
+	}
+	if o, err := f.LoadMedia(loc); err == nil {
+		f.media.set(o.ID, loc)
+	}
+	return nil
+}
+
+func (f *Filesystem) DeletedMedia() ([]Deleted, error) {
+	return f.listTrash(Media)
+}
+
+// delete moves something into the trash, at is when it's logged as deleted which is the stamp it's kept under
+func (f *Filesystem) delete(p wikithing.Path, pre string, why wikithing.LogEntry, at time.Time) error {
+	err := f.deleteLocked(p, pre, why, at)
+
+	// taking the lock makes the directory if it wasn't there, and once the files are gone it's empty,
+	// this fails if there's anything under it which is fine since then the directory still has a use
+	f.FS.Remove(path.Join(pre, p.Path()))
+	return err
+}
+
+// deleteLocked takes the lock before checking there's anything to delete and moving it into the trash
+func (f *Filesystem) deleteLocked(p wikithing.Path, pre string, why wikithing.LogEntry, at time.Time) error {
+	unlock, err := f.getLock(p, pre)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := f.FS.Stat(pathCurrent(p, pre)); err != nil {
+		return err
+	}
+
+	why.When = at
+	why.Action = wikithing.LogActionDelete
+	err = f.appendLog(p, pre, why)
+	if err != nil {
+		return err
+	}
+	return f.moveFiles(path.Join(pre, p.Path()), trashDir(p, pre, why.When.Format(TimeFormat)))
+}
+
+func (f *Filesystem) restore(p wikithing.Path, pre, stamp string, why wikithing.LogEntry) error {
+	err := CheckStamp(stamp)
+	if err != nil {
+		return err
+	}
+
+	err = f.restoreLocked(p, pre, stamp, why)
+	if err != nil {
+		// like with moves the directory the lock made is taken away again if nothing came back into it
+		f.FS.Remove(path.Join(pre, p.Path()))
+	}
+	return err
+}
+
+// restoreLocked takes the lock before checking there's nothing in the way and bringing something back out of the trash
+func (f *Filesystem) restoreLocked(p wikithing.Path, pre, stamp string, why wikithing.LogEntry) error {
+	err := f.FS.MkdirAll(path.Join(pre, p.Path()), 0775)
+	if err != nil {
+		return err
+	}
+	unlock, err := f.getLock(p, pre)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dir := trashDir(p, pre, stamp)
+	if _, err := f.FS.Stat(dir); err != nil {
+		return err
+	}
+	if _, err := f.FS.Stat(pathLog(p, pre)); err == nil {
+		return os.ErrExist
+	}
+
+	err = f.moveFiles(dir, path.Join(pre, p.Path()))
+	if err != nil {
+		return err
+	}
+	err = f.FS.Remove(dir)
+	if err != nil {
+		return err
+	}
+
+	why.When = time.Now().UTC()
+	why.Action = wikithing.LogActionRestore
+	return f.appendLog(p, pre, why)
+}
+
+// moveFiles moves all the files in a directory to another, leaving any directories in it alone
+func (f *Filesystem) moveFiles(from, to string) error {
+	err := f.FS.MkdirAll(to, 0775)
+	if err != nil {
+		return err
+	}
+
+	l, err := f.FS.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, x := range l {
+		if x.IsDir() || x.Name() == manLock+Extension {
+			continue
+		}
+		err = f.FS.Rename(path.Join(from, x.Name()), path.Join(to, x.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Filesystem) listTrash(pre string) ([]Deleted, error) {
+	dir := path.Join(Trash, pre)
+	l, err := f.FS.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Deleted
+	for _, x := range l {
+		i := strings.LastIndexByte(x.Name(), '@')
+		if !x.IsDir() || i < 0 {
+			continue
+		}
+		d := Deleted{
+			Path:  wikithing.ParsePath(x.Name()[:i]),
+			Stamp: x.Name()[i+1:],
+		}
+
+		var log wikithing.LogFile
+		err := f.readJSON(path.Join(dir, x.Name(), manLog+Extension), &log)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(log.Entries); n > 0 {
+			d.Entry = log.Entries[n-1]
+		}
+
+		out = append(out, d)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Stamp > out[j].Stamp })
+	return out, nil
+}
+
+// readJSON reads a file that isn't being managed, so there is no lock to take
+func (f *Filesystem) readJSON(file string, dat interface{}) error {
+	d, err := f.FS.Open(file)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return json.NewDecoder(d).Decode(dat)
+}
@@ -0,0 +1,104 @@
+package wtfs
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"git.lan/wikithing"
+)
+
+func TestDeleteTalk(t *testing.T) {
+	f := NewMemory()
+	loc := wikithing.ParsePath("discussed")
+
+	err := f.SavePage(loc, article("page"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.UpdateTalk(loc, wikithing.LogEntry{}, func(d *wikithing.Discussion) error {
+		d.Comments = append(d.Comments, wikithing.Comment{Body: "about the page"})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.DeletePage(loc, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.LoadTalk(loc); !os.IsNotExist(err) {
+		t.Fatalf("the discussion is still there after deleting the page (%v)", err)
+	}
+
+	l, err := f.DeletedPages()
+	if err != nil || len(l) != 1 {
+		t.Fatalf("got %v %v from the trash", l, err)
+	}
+	err = f.RestorePage(loc, l[0].Stamp, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := f.LoadTalk(loc)
+	if err != nil {
+		t.Fatalf("the discussion didn't come back with the page: %v", err)
+	}
+	if len(d.Comments) != 1 || d.Comments[0].Body != "about the page" {
+		t.Errorf("the discussion came back as %v", d)
+	}
+}
+
+func TestRestoreStamp(t *testing.T) {
+	f := NewMemory()
+	loc := wikithing.ParsePath("gone")
+
+	err := f.SavePage(loc, article("page"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.DeletePage(loc, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := f.DeletedPages()
+	if err != nil || len(l) != 1 {
+		t.Fatalf("got %v %v from the trash", l, err)
+	}
+
+	for _, x := range []string{"", "../..", "../../../pages/gone", "yesterday", l[0].Stamp + "/.."} {
+		if err := f.RestorePage(loc, x, wikithing.LogEntry{}); err != ErrBadStamp {
+			t.Errorf("restoring with the stamp %q gave %v", x, err)
+		}
+	}
+
+	// a stamp that's fine but that nothing was deleted at doesn't leave anything behind
+	err = f.RestorePage(loc, "2001-02-03_04:05:06", wikithing.LogEntry{})
+	if !os.IsNotExist(err) {
+		t.Errorf("restoring something that isn't in the trash gave %v", err)
+	}
+	if _, err := f.FS.Stat(path.Join(Pages, loc.Path())); !os.IsNotExist(err) {
+		t.Errorf("restoring something that isn't in the trash left its directory (%v)", err)
+	}
+
+	err = f.RestorePage(loc, l[0].Stamp, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.LoadPage(loc); err != nil {
+		t.Errorf("the page didn't come back: %v", err)
+	}
+}
+
+func TestDeleteMissing(t *testing.T) {
+	f := NewMemory()
+	loc := wikithing.ParsePath("not/here")
+
+	err := f.DeletePage(loc, wikithing.LogEntry{})
+	if !os.IsNotExist(err) {
+		t.Fatalf("deleting a page that isn't there gave %v", err)
+	}
+	if _, err := f.FS.Stat(path.Join(Pages, loc.Path())); !os.IsNotExist(err) {
+		t.Errorf("deleting a page that isn't there left its directory (%v)", err)
+	}
+}
@@ -0,0 +1,88 @@
+package wtfs
+
+import (
+	"os"
+	"path"
+	"sort"
+
+	"git.lan/wikithing"
+)
+
+// Child is something directly under a page, it might just be a namespace for other pages and not exist itself
+type Child struct {
+	Path wikithing.Path
+
+	// Exists is whether there is a page here
+	Exists bool
+	// HasChildren is whether anything is under this
+	HasChildren bool
+}
+
+// ListChildren lists everything directly under a page, sorted by name,
+// the zero path lists everything at the top
+func (f *Filesystem) ListChildren(loc wikithing.Path) ([]Child, error) {
+	return f.listChildren(loc, Pages)
+}
+
+// Walk calls fn with everything under a page, parents before their children,
+// namespaces that don't exist as pages are included too
+func (f *Filesystem) Walk(loc wikithing.Path, fn func(Child) error) error {
+	l, err := f.ListChildren(loc)
+	if err != nil {
+		return err
+	}
+
+	for _, x := range l {
+		if err := fn(x); err != nil {
+			return err
+		}
+		if !x.HasChildren {
+			continue
+		}
+		if err := f.Walk(x.Path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Filesystem) listChildren(p wikithing.Path, pre string) ([]Child, error) {
+	dir := path.Join(pre, p.Path())
+	l, err := f.FS.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Child
+	for _, x := range l {
+		if !x.IsDir() {
+			continue
+		}
+		c := Child{Path: p.Child(x.Name())}
+		// skip anything that wouldn't survive being turned into a path and back
+		if c.Path.Base() != x.Name() {
+			continue
+		}
+
+		sub, err := f.FS.ReadDir(path.Join(dir, x.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, y := range sub {
+			switch {
+			case y.IsDir():
+				c.HasChildren = true
+			case y.Name() == manCurrent+Extension:
+				c.Exists = true
+			}
+		}
+
+		out = append(out, c)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Path.Base() < out[j].Path.Base() })
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package wtfs
+
+import (
+	"os"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+)
+
+// Users is the folder user accounts go in
+const Users = "users"
+
+func userPath(name string) wikithing.Path { return wikithing.ParsePath(name) }
+
+func (f *Filesystem) LoadUser(name string) (u wikithing.User, err error) {
+	if !wikithing.ValidUsername(name) {
+		return u, os.ErrNotExist
+	}
+	return u, f.loadFile(userPath(name), Users, &u)
+}
+
+func (f *Filesystem) SaveUser(u wikithing.User, why wikithing.LogEntry) error {
+	return f.updateFile(userPath(u.Name), Users, why, u)
+}
+
+// CreateUser saves a new user, failing with os.ErrExist if the name is already taken
+func (f *Filesystem) CreateUser(u wikithing.User, why wikithing.LogEntry) error {
+	return f.updateFileAs(userPath(u.Name), Users, wikithing.LogActionCreate, why, u)
+}
+
+// UserByID looks through all the users for the one with the given ID
+func (f *Filesystem) UserByID(id sid.ID) (u wikithing.User, err error) {
+	err = f.walk(Users, func(p wikithing.Path) error {
+		var x wikithing.User
+		if err := f.loadFile(p, Users, &x); err != nil {
+			return err
+		}
+		if x.ID != id {
+			return nil
+		}
+		u = x
+		return errStopWalk
+	})
+	switch err {
+	case errStopWalk:
+		return u, nil
+	case nil:
+		return u, os.ErrNotExist
+	}
+	return u, err
+}
+
+// ListUsers loads every user
+func (f *Filesystem) ListUsers() (l []wikithing.User, err error) {
+	err = f.walk(Users, func(p wikithing.Path) error {
+		var x wikithing.User
+		if err := f.loadFile(p, Users, &x); err != nil {
+			return err
+		}
+		l = append(l, x)
+		return nil
+	})
+	return l, err
+}
@@ -3,16 +3,23 @@ package wtfs
 import (
 	"encoding/json"
 	"io"
-	"log"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"git.lan/wikithing"
+	"github.com/go-git/go-billy/v5"
 )
 
-// TimeFormat is the format used in past version timestamps
-const TimeFormat = "2006-01-02_15:04:05"
+// TimeFormat is the format used in past version timestamps,
+// it goes down to the nanosecond so edits made close together don't end up with the same name
+const TimeFormat = "2006-01-02_15:04:05.000000000"
+
+// oldTimeFormat is what TimeFormat used to be, revisions saved before it changed are still named with it
+const oldTimeFormat = "2006-01-02_15:04:05"
 
 const (
 	manLock    = ".lockfile"
@@ -26,118 +33,176 @@ func pathLog(p wikithing.Path, pre string) string     { return pathSub(p, pre, m
 func pathWhen(p wikithing.Path, x string, at time.Time) string {
 	return pathSub(p, x, at.Format(TimeFormat))
 }
+
+// whenName gets the name of the revision file saved at a time, falling back to oldTimeFormat for ones saved before TimeFormat changed
+func (f *Filesystem) whenName(p wikithing.Path, pre string, at time.Time) string {
+	name := at.Format(TimeFormat)
+	if _, err := f.FS.Stat(pathSub(p, pre, name)); os.IsNotExist(err) {
+		old := at.Format(oldTimeFormat)
+		if _, err := f.FS.Stat(pathSub(p, pre, old)); err == nil {
+			return old
+		}
+	}
+	return name
+}
+
 func pathSub(p wikithing.Path, prefix, sub string) string {
 	return path.Join(prefix, p.Path(), sub+Extension)
 }
 
-func (f *Filesystem) saveJSON(p wikithing.Path, pre, sub string, dat interface{}) error {
-	unlock, err := f.getLock(p, pre)
-	if err != nil {
-		return err
-	}
-	defer unlock()
-
-	d, err := f.FS.OpenFile(pathSub(p, pre, sub), os.O_RDWR|os.O_CREATE, 0664)
-	defer d.Close()
-	if err != nil {
-		return err
-	}
+// writeJSON writes a managed file, the lock should already be held
+func (f *Filesystem) writeJSON(p wikithing.Path, pre, sub string, dat interface{}) error {
+	return f.writeJSONFile(pathSub(p, pre, sub), dat)
+}
 
-	var j *json.Encoder
-	j = json.NewEncoder(d)
-	j.SetIndent("", "	")
-	j.SetEscapeHTML(false)
-	return j.Encode(dat)
+func (f *Filesystem) writeJSONFile(file string, dat interface{}) error {
+	return f.writeFile(file, func(w io.Writer) error {
+		j := json.NewEncoder(w)
+		j.SetIndent("", "	")
+		j.SetEscapeHTML(false)
+		return j.Encode(dat)
+	})
 }
 
-func (f *Filesystem) loadJSON(p wikithing.Path, pre, sub string, dat interface{}) error {
-	unlock, err := f.getLock(p, pre)
+// tmpSuffix goes after the name of a file being written to make the name of the temporary file it's written to first
+const tmpSuffix = ".tmp"
+
+// fileMode is what new files are made with, before the umask
+const fileMode = 0664
+
+// tmpCount makes the names of temporary files unique within a process
+var tmpCount uint64
+
+// writeFile replaces a file with what fn writes, it's written to a temporary file next to it which is synced and renamed over the old one,
+// so if anything goes wrong part way through the old version is left as it was rather than half of the new one
+func (f *Filesystem) writeFile(file string, fn func(w io.Writer) error) error {
+	t, err := f.tempFile(file)
 	if err != nil {
 		return err
 	}
-	defer unlock()
 
-	d, err := f.FS.OpenFile(pathSub(p, pre, sub), os.O_RDWR|os.O_CREATE, 0664)
-	defer d.Close()
+	err = fn(t)
+	if cerr := t.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = f.sync(t.Name())
+	}
+	if err == nil {
+		err = f.FS.Rename(t.Name(), file)
+	}
 	if err != nil {
+		f.FS.Remove(t.Name())
 		return err
 	}
-
-	return json.NewDecoder(d).Decode(dat)
+	// the rename itself isn't safe until the directory is synced too
+	return f.sync(path.Dir(file))
 }
 
-func (f *Filesystem) atomicUpdateJSON(p wikithing.Path, pre, sub string,
-	/**/ dat interface{}, fn func() error) error {
-
-	d, err := f.FS.OpenFile(pathSub(p, pre, sub), os.O_RDWR|os.O_CREATE, 0664)
-	defer d.Close()
-	if err != nil {
-		return err
-	}
-	err = d.Lock()
-	if err != nil {
-		return err
+// tempFile makes the temporary file for writing a file, with the same permissions as the file if it's already there
+// and otherwise the ones a new file would get
+func (f *Filesystem) tempFile(file string) (billy.File, error) {
+	mode, keep := os.FileMode(fileMode), false
+	if i, err := f.FS.Stat(file); err == nil {
+		mode, keep = i.Mode().Perm(), true
 	}
-	defer func() {
-		err := d.Unlock()
+
+	for {
+		name := file + tmpSuffix + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatUint(atomic.AddUint64(&tmpCount, 1), 10)
+		t, err := f.FS.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+		if os.IsExist(err) {
+			// left behind by an earlier process with the same pid
+			continue
+		}
 		if err != nil {
-			// this better not ever happen
-			log.Println("!!!UH OH BIG ERROR. FAILED TO RELEASE FILE LOCK ON: `", pathSub(p, pre, sub), "` BECAUSE:", err)
+			return nil, err
 		}
-	}()
 
-	err = json.NewDecoder(d).Decode(dat)
-	if err != nil {
-		return err
+		// the umask might have taken some of the permissions away
+		if keep {
+			if err := f.chmod(name, mode); err != nil {
+				t.Close()
+				f.FS.Remove(name)
+				return nil, err
+			}
+		}
+		return t, nil
 	}
-	err = fn()
-	if err != nil {
-		return err
+}
+
+// chmod changes the permissions of a file on disk, billy can't do it so filesystems that aren't on disk are left alone
+func (f *Filesystem) chmod(name string, mode os.FileMode) error {
+	if f.dir == "" {
+		return nil
 	}
-	err = d.Truncate(0)
+	return os.Chmod(filepath.Join(f.dir, filepath.FromSlash(name)), mode)
+}
+
+// sync makes sure a file or directory has been written to disk, there's nothing to do for filesystems that aren't on disk
+func (f *Filesystem) sync(name string) error {
+	if f.dir == "" {
+		return nil
+	}
+	d, err := os.Open(filepath.Join(f.dir, filepath.FromSlash(name)))
 	if err != nil {
 		return err
 	}
-
-	var j *json.Encoder
-	j = json.NewEncoder(d)
-	j.SetIndent("", "	")
-	j.SetEscapeHTML(false)
-	return j.Encode(dat)
+	defer d.Close()
+	return d.Sync()
 }
 
-func (f *Filesystem) moveFile(p wikithing.Path, pre string, subfrom, subto string) error {
-	return f.FS.Rename(pathSub(p, pre, subfrom), pathSub(p, pre, subto))
+// loadJSON reads a managed file, there's no need for the lock since writeFile never leaves anything half written
+func (f *Filesystem) loadJSON(p wikithing.Path, pre, sub string, dat interface{}) error {
+	return f.readJSON(pathSub(p, pre, sub), dat)
 }
 
-func (f *Filesystem) copyFile(p wikithing.Path, pre string, subfrom, subto string) error {
-	d, err := f.FS.OpenFile(pathSub(p, pre, subfrom), os.O_RDONLY, 0664)
-	defer d.Close()
+// copyFile copies a file, replacing anything already at to
+func (f *Filesystem) copyFile(from, to string) error {
+	d, err := f.FS.Open(from)
 	if err != nil {
 		return err
 	}
+	defer d.Close()
 
-	t, err := f.FS.OpenFile(pathSub(p, pre, subto), os.O_RDWR|os.O_CREATE, 0664)
-	defer t.Close()
-	if err != nil {
+	return f.writeFile(to, func(w io.Writer) error {
+		_, err := io.Copy(w, d)
 		return err
-	}
+	})
+}
 
-	_, err = io.Copy(t, d)
-	return err
+// walk calls fn with every path under a prefix that has a current version, parents before their children
+func (f *Filesystem) walk(pre string, fn func(wikithing.Path) error) error {
+	return f.walkDir(pre, "", fn)
 }
 
-// getLock fetches the lock file for a managed data group preventing other concurrent processes from also modifying it
-func (f *Filesystem) getLock(p wikithing.Path, prefix string) (func(), error) {
-	file := pathLock(p, prefix)
-	l, err := f.FS.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
+func (f *Filesystem) walkDir(pre, dir string, fn func(wikithing.Path) error) error {
+	l, err := f.FS.ReadDir(path.Join(pre, dir))
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
-		l.Close()
-		return nil, err
+		return err
+	}
+
+	if dir != "" {
+		for _, x := range l {
+			if x.Name() != manCurrent+Extension || x.IsDir() {
+				continue
+			}
+			if err := fn(wikithing.ParsePath(dir)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, x := range l {
+		if !x.IsDir() {
+			continue
+		}
+		if err := f.walkDir(pre, path.Join(dir, x.Name()), fn); err != nil {
+			return err
+		}
 	}
 
-	return func() {
-		l.Close()
-		f.FS.Remove(file)
-	}, nil
+	return nil
 }
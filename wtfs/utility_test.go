@@ -0,0 +1,74 @@
+package wtfs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"git.lan/wikithing"
+)
+
+func TestWritePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows doesn't have unix permissions")
+	}
+
+	dir := t.TempDir()
+	f, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// what a file made the way they used to be gets after the umask
+	ref, err := os.OpenFile(filepath.Join(dir, "ref"), os.O_CREATE|os.O_WRONLY, fileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.Close()
+	i, err := os.Stat(filepath.Join(dir, "ref"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := i.Mode().Perm()
+
+	loc := wikithing.ParsePath("a/page")
+	mode := func(sub string) os.FileMode {
+		t.Helper()
+		i, err := os.Stat(filepath.Join(dir, filepath.FromSlash(pathSub(loc, Pages, sub))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return i.Mode().Perm()
+	}
+
+	err = f.SavePage(loc, article("first"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []string{manCurrent, manLog} {
+		if got := mode(x); got != want {
+			t.Errorf("new %v is %v, not %v", x, got, want)
+		}
+	}
+
+	// rewriting a file keeps whatever it was changed to
+	err = os.Chmod(filepath.Join(dir, filepath.FromSlash(pathCurrent(loc, Pages))), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := f.PageStamp(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.SavePage(loc, article("second"), base, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mode(manCurrent); got != 0640 {
+		t.Errorf("rewritten current is %v, not %v", got, os.FileMode(0640))
+	}
+	if got := mode(manLog); got != want {
+		t.Errorf("rewritten log is %v, not %v", got, want)
+	}
+}
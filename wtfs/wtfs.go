@@ -4,7 +4,9 @@ import (
 	"errors"
 	"os"
 
+	"git.lan/wikithing"
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/osfs"
 )
 
@@ -13,8 +15,53 @@ const Extension = ".json"
 // Filesystem contains everything needed to access the filesystem
 type Filesystem struct {
 	FS billy.Filesystem
+
+	// Locking is how long to wait for locks and when to break them
+	Locking LockOptions
+
+	// dir is where FS is on disk, so writes can be synced to it, it's empty for memory
+	dir string
+
+	media mediaIndex
+
+	Hooks
+}
+
+// PageHook gets called after a page is saved, hooks can run out of order so they should load it again rather than trusting the article it's handed
+type PageHook func(loc wikithing.Path, a wikithing.Article)
+
+// Hooks keeps the functions to call when pages change, for Store implementations to embed
+type Hooks struct {
+	page   []PageHook
+	remove []func(loc wikithing.Path)
 }
 
+// OnPageChange adds a hook that's called whenever a page is saved or reverted,
+// it should be set up before the store starts being used
+func (h *Hooks) OnPageChange(fn PageHook) {
+	h.page = append(h.page, fn)
+}
+
+// OnPageRemove adds a hook that's called whenever a page stops existing at a path
+func (h *Hooks) OnPageRemove(fn func(loc wikithing.Path)) {
+	h.remove = append(h.remove, fn)
+}
+
+// PageRemoved calls the hooks for a page being removed
+func (h *Hooks) PageRemoved(loc wikithing.Path) {
+	for _, x := range h.remove {
+		x(loc)
+	}
+}
+
+// PageChanged calls the hooks for a page being changed
+func (h *Hooks) PageChanged(loc wikithing.Path, a wikithing.Article) {
+	for _, x := range h.page {
+		x(loc, a)
+	}
+}
+
+// New opens the data kept in a directory on disk
 func New(dir string) (*Filesystem, error) {
 	i, err := os.Lstat(dir)
 	if err != nil {
@@ -27,8 +74,15 @@ func New(dir string) (*Filesystem, error) {
 	ofs := osfs.New(dir)
 
 	f := Filesystem{
-		FS: ofs,
+		FS:      ofs,
+		Locking: DefaultLockOptions,
+		dir:     dir,
 	}
 
 	return &f, nil
 }
+
+// NewMemory makes a filesystem that only exists in memory, starting out empty, for tests and trying things out
+func NewMemory() *Filesystem {
+	return &Filesystem{FS: newSyncFS(memfs.New()), Locking: DefaultLockOptions}
+}
@@ -0,0 +1,117 @@
+package wtgit
+
+import (
+	"os"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+func (r *Repo) PageLog(loc wikithing.Path) (wikithing.LogFile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.logs[loc.String()]
+	if !ok {
+		return wikithing.LogFile{}, os.ErrNotExist
+	}
+	return logFile(l), nil
+}
+
+// PageRevisions lists every revision of a page, oldest first, each one is a commit
+func (r *Repo) PageRevisions(loc wikithing.Path) ([]wtfs.Revision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.logs[loc.String()]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	revs := make([]wtfs.Revision, 0, len(l))
+	for _, x := range l {
+		if !wtfs.ChangesContent(x.Action) {
+			continue
+		}
+		revs = append(revs, wtfs.Revision{
+			LogEntry: x.LogEntry,
+			Stamp:    x.When.Format(wtfs.TimeFormat),
+		})
+	}
+
+	if len(revs) > 0 {
+		ok, err := r.exists(file(loc))
+		if err != nil {
+			return nil, err
+		}
+		revs[len(revs)-1].Current = ok
+	}
+	return revs, nil
+}
+
+// LoadPageRevision loads a page as it was in the commit that made a revision
+func (r *Repo) LoadPageRevision(loc wikithing.Path, at time.Time) (a wikithing.Article, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.revision(loc, at)
+	if !ok {
+		return a, os.ErrNotExist
+	}
+	return a, r.read(e.Commit, e.File, &a)
+}
+
+func (r *Repo) revision(p wikithing.Path, at time.Time) (entry, bool) {
+	stamp := at.UTC().Format(wtfs.TimeFormat)
+	for _, x := range r.logs[p.String()] {
+		if wtfs.ChangesContent(x.Action) && x.When.Format(wtfs.TimeFormat) == stamp {
+			return x, true
+		}
+	}
+	return entry{}, false
+}
+
+// RevertPage commits an old revision of a page again as a new revision
+func (r *Repo) RevertPage(loc wikithing.Path, at time.Time, why wikithing.LogEntry) error {
+	var a wikithing.Article
+	err := func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		e, ok := r.revision(loc, at)
+		if !ok {
+			return os.ErrNotExist
+		}
+		err := r.read(e.Commit, e.File, &a)
+		if err != nil {
+			return err
+		}
+		b, err := encode(a)
+		if err != nil {
+			return err
+		}
+
+		why.When = time.Now().UTC()
+		why.Action = wikithing.LogActionRevert
+		why.Ref = at.UTC().Format(wtfs.TimeFormat)
+		return r.commit(loc, why, map[string][]byte{file(loc): b})
+	}()
+	if err != nil {
+		return err
+	}
+	r.PageChanged(loc, a)
+	return nil
+}
+
+// LogPageAction adds an entry to the log of a page without changing it, which is a commit that doesn't change anything
+func (r *Repo) LogPageAction(loc wikithing.Path, why wikithing.LogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.logs[loc.String()]; !ok {
+		return os.ErrNotExist
+	}
+	why.When = time.Now().UTC()
+	return r.commit(loc, why, nil)
+}
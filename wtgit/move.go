@@ -0,0 +1,78 @@
+package wtgit
+
+import (
+	"os"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+// MovePage moves a page to a new path in a single commit so git sees it as a rename, the history of the page goes with it.
+// Its discussion is moved too, and if redirect is set a page redirecting to the new path is committed after
+func (r *Repo) MovePage(from, to wikithing.Path, why wikithing.LogEntry, redirect bool) error {
+	var a wikithing.Article
+	stub := wikithing.Article{Redirect: to.String()}
+
+	err := r.CheckTalkMove(from, to)
+	if err != nil {
+		return err
+	}
+
+	err = func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if from.Equal(to) {
+			return os.ErrExist
+		}
+		if _, ok := r.logs[to.String()]; ok {
+			return os.ErrExist
+		}
+		err := r.readCurrent(file(from), &a)
+		if err != nil {
+			return err
+		}
+		b, err := encode(a)
+		if err != nil {
+			return err
+		}
+
+		mv := why
+		mv.When = time.Now().UTC()
+		mv.Action = wikithing.LogActionMove
+		mv.Ref = wikithing.MoveRef(from, to)
+		err = r.commit(to, mv, map[string][]byte{file(from): nil, file(to): b})
+		if err != nil {
+			return err
+		}
+
+		if !redirect {
+			return nil
+		}
+		b, err = encode(stub)
+		if err != nil {
+			return err
+		}
+		rd := why
+		rd.When = time.Now().UTC()
+		rd.Action = wikithing.LogActionCreate
+		rd.Reason = "Redirect to " + to.String()
+		return r.commit(from, rd, map[string][]byte{file(from): b})
+	}()
+	if err != nil {
+		return err
+	}
+
+	var talkErr error
+	if err := r.MoveTalk(from, to, why); err != nil {
+		talkErr = wtfs.TalkLeftBehind(from, err)
+	}
+
+	r.PageRemoved(from)
+	r.PageChanged(to, a)
+	if redirect {
+		r.PageChanged(from, stub)
+	}
+	return talkErr
+}
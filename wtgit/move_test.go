@@ -0,0 +1,64 @@
+package wtgit
+
+import (
+	"os"
+	"testing"
+
+	"git.lan/wikithing"
+)
+
+func TestMovePage(t *testing.T) {
+	for _, redirect := range []bool{false, true} {
+		r, _ := newRepo(t)
+		from, to := wikithing.ParsePath("from"), wikithing.ParsePath("to")
+
+		err := r.SavePage(from, article("page"), "", wikithing.LogEntry{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.UpdateTalk(from, wikithing.LogEntry{}, func(d *wikithing.Discussion) error {
+			d.Comments = append(d.Comments, wikithing.Comment{Body: "about the page"})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = r.MovePage(from, to, wikithing.LogEntry{Reason: "moving"}, redirect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := body(t, r, to); b != "page" {
+			t.Errorf("the moved page is %q", b)
+		}
+		if _, err := r.LoadTalk(to); err != nil {
+			t.Errorf("the discussion didn't move with the page: %v", err)
+		}
+
+		a, err := r.LoadPage(from)
+		switch {
+		case redirect && (err != nil || a.Redirect != to.String()):
+			t.Errorf("no redirect was left behind: %v %v", a, err)
+		case !redirect && !os.IsNotExist(err):
+			t.Errorf("something was left behind without a redirect: %v %v", a, err)
+		}
+
+		l, err := r.PageLog(to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(l.Entries); n != 2 || l.Entries[n-1].Action != wikithing.LogActionMove {
+			t.Errorf("the moved page's log is %+v", l)
+		}
+
+		// nothing can be moved onto the old path's history
+		err = r.SavePage(wikithing.ParsePath("other"), article("other"), "", wikithing.LogEntry{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.MovePage(wikithing.ParsePath("other"), from, wikithing.LogEntry{}, false)
+		if !os.IsExist(err) {
+			t.Errorf("moving onto the old path gave %v", err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package wtgit
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"git.lan/wikithing"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// head gets the latest commit, it's nil if nothing has been committed yet
+func (r *Repo) head() (*object.Commit, error) {
+	ref, err := r.Git.Reference(plumbing.HEAD, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.Git.CommitObject(ref.Hash())
+}
+
+// branch is the name of the branch HEAD points to, which is where new commits go
+func (r *Repo) branch() (plumbing.ReferenceName, error) {
+	ref, err := r.Git.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+	if ref.Type() != plumbing.SymbolicReference {
+		return plumbing.Master, nil
+	}
+	return ref.Target(), nil
+}
+
+// readAt reads the json in a file as it was in a commit
+func (r *Repo) readAt(c *object.Commit, name string, dat interface{}) error {
+	if c == nil {
+		return os.ErrNotExist
+	}
+	f, err := c.File(name)
+	if err == object.ErrFileNotFound {
+		return os.ErrNotExist
+	}
+	if err != nil {
+		return err
+	}
+	rd, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	return json.NewDecoder(rd).Decode(dat)
+}
+
+func (r *Repo) read(h plumbing.Hash, name string, dat interface{}) error {
+	c, err := r.Git.CommitObject(h)
+	if err != nil {
+		return err
+	}
+	return r.readAt(c, name, dat)
+}
+
+// readCurrent reads the latest version of a file
+func (r *Repo) readCurrent(name string, dat interface{}) error {
+	c, err := r.head()
+	if err != nil {
+		return err
+	}
+	return r.readAt(c, name, dat)
+}
+
+// exists checks if there is a file in the latest commit
+func (r *Repo) exists(name string) (bool, error) {
+	c, err := r.head()
+	if err != nil || c == nil {
+		return false, err
+	}
+	_, err = c.File(name)
+	if err == object.ErrFileNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// encode turns something into the contents of a file, indented so diffs of it are readable
+func encode(dat interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(dat, "", "\t")
+	return append(b, '\n'), err
+}
+
+// commit makes a commit on top of the latest one, with the files in changes written or deleted if they're nil,
+// and adds its entry to the log of p
+func (r *Repo) commit(p wikithing.Path, le wikithing.LogEntry, changes map[string][]byte) error {
+	parent, err := r.head()
+	if err != nil {
+		return err
+	}
+
+	var tree *object.Tree
+	var parents []plumbing.Hash
+	if parent != nil {
+		tree, err = parent.Tree()
+		if err != nil {
+			return err
+		}
+		parents = []plumbing.Hash{parent.Hash}
+	}
+
+	th, err := r.editTree(tree, changes)
+	if err != nil {
+		return err
+	}
+
+	name := le.Actor.String()
+	if u, err := r.UserByID(le.Actor); err == nil {
+		name = u.Name
+	}
+	sig := object.Signature{Name: name, When: le.When}
+
+	c := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      message(p, le),
+		TreeHash:     th,
+		ParentHashes: parents,
+	}
+	obj := r.Git.Storer.NewEncodedObject()
+	if err := c.Encode(obj); err != nil {
+		return err
+	}
+	h, err := r.Git.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	b, err := r.branch()
+	if err != nil {
+		return err
+	}
+	err = r.Git.Storer.SetReference(plumbing.NewHashReference(b, h))
+	if err != nil {
+		return err
+	}
+
+	r.record(p, entry{LogEntry: le, Commit: h, File: file(p)})
+	return nil
+}
+
+// editTree makes a new tree from an old one (nil for an empty one) with changes made to it,
+// the paths in changes are relative to the tree
+func (r *Repo) editTree(t *object.Tree, changes map[string][]byte) (plumbing.Hash, error) {
+	entries := map[string]object.TreeEntry{}
+	if t != nil {
+		for _, x := range t.Entries {
+			entries[x.Name] = x
+		}
+	}
+
+	sub := map[string]map[string][]byte{}
+	for name, dat := range changes {
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			if sub[name[:i]] == nil {
+				sub[name[:i]] = map[string][]byte{}
+			}
+			sub[name[:i]][name[i+1:]] = dat
+			continue
+		}
+
+		if dat == nil {
+			delete(entries, name)
+			continue
+		}
+		h, err := r.writeBlob(dat)
+		if err != nil {
+			return h, err
+		}
+		entries[name] = object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: h}
+	}
+
+	for name, ch := range sub {
+		var st *object.Tree
+		if x, ok := entries[name]; ok && x.Mode == filemode.Dir {
+			var err error
+			st, err = r.Git.TreeObject(x.Hash)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+
+		h, err := r.editTree(st, ch)
+		if err != nil {
+			return h, err
+		}
+		// git has no empty directories
+		if h == emptyTree {
+			delete(entries, name)
+			continue
+		}
+		entries[name] = object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: h}
+	}
+
+	nt := &object.Tree{}
+	for _, x := range entries {
+		nt.Entries = append(nt.Entries, x)
+	}
+	// git sorts directories as though they end with /
+	sortName := func(x object.TreeEntry) string {
+		if x.Mode == filemode.Dir {
+			return x.Name + "/"
+		}
+		return x.Name
+	}
+	sort.Slice(nt.Entries, func(i, j int) bool { return sortName(nt.Entries[i]) < sortName(nt.Entries[j]) })
+
+	obj := r.Git.Storer.NewEncodedObject()
+	if err := nt.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return r.Git.Storer.SetEncodedObject(obj)
+}
+
+// emptyTree is the hash of a tree with nothing in it
+var emptyTree = plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+
+func (r *Repo) writeBlob(dat []byte) (plumbing.Hash, error) {
+	obj := r.Git.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(dat); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return r.Git.Storer.SetEncodedObject(obj)
+}
+
+// tree gets the tree for a directory in the latest commit, it's nil if there isn't one
+func (r *Repo) tree(dir string) (*object.Tree, error) {
+	c, err := r.head()
+	if err != nil || c == nil {
+		return nil, err
+	}
+	t, err := c.Tree()
+	if err != nil || dir == "" {
+		return t, err
+	}
+	t, err = t.Tree(path.Clean(dir))
+	if err == object.ErrDirectoryNotFound {
+		return nil, nil
+	}
+	return t, err
+}
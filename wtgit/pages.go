@@ -0,0 +1,86 @@
+package wtgit
+
+import (
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+func (r *Repo) LoadPage(loc wikithing.Path) (a wikithing.Article, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return a, r.readCurrent(file(loc), &a)
+}
+
+// PageStamp gets the stamp of the current revision of a page, it's empty if the page doesn't exist
+func (r *Repo) PageStamp(loc wikithing.Path) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.currentStamp(loc)
+}
+
+func (r *Repo) currentStamp(p wikithing.Path) (string, error) {
+	ok, err := r.exists(file(p))
+	if err != nil || !ok {
+		return "", err
+	}
+	e, _ := lastContent(r.logs[p.String()])
+	if e.When.IsZero() {
+		return "", nil
+	}
+	return e.When.Format(wtfs.TimeFormat), nil
+}
+
+// SavePage commits a new revision of a page if it's still at the revision base, otherwise it fails with wtfs.ErrConflict
+func (r *Repo) SavePage(loc wikithing.Path, a wikithing.Article, base string, why wikithing.LogEntry) error {
+	err := r.savePage(loc, a, base, why)
+	if err != nil {
+		return err
+	}
+	r.PageChanged(loc, a)
+	return nil
+}
+
+func (r *Repo) savePage(loc wikithing.Path, a wikithing.Article, base string, why wikithing.LogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cur, err := r.currentStamp(loc)
+	if err != nil {
+		return err
+	}
+	if cur != base {
+		return wtfs.ErrConflict
+	}
+
+	why.Action = wikithing.LogActionEdit
+	if cur == "" {
+		why.Action = wikithing.LogActionCreate
+	}
+	why.When = time.Now().UTC()
+
+	b, err := encode(a)
+	if err != nil {
+		return err
+	}
+	return r.commit(loc, why, map[string][]byte{file(loc): b})
+}
+
+func (r *Repo) FollowRedirects(loc wikithing.Path) ([]wikithing.Path, error) {
+	return wtfs.ResolveRedirects(r.LoadPage, loc)
+}
+
+// WalkPages calls fn with every page, parents before their children
+func (r *Repo) WalkPages(fn func(loc wikithing.Path, a wikithing.Article) error) error {
+	l, err := r.allPages()
+	if err != nil {
+		return err
+	}
+	for _, x := range l {
+		if err := fn(x.loc, x.a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package wtgit
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+func article(body string) wikithing.Article {
+	return wikithing.Article{Pages: []wikithing.Page{{Title: "Test", Body: body, Format: wikithing.FormatMarkdown}}}
+}
+
+// newRepo makes a repository and data directory that last for the test, reopen opens them again the way starting the wiki does
+func newRepo(t *testing.T) (r *Repo, reopen func() *Repo) {
+	t.Helper()
+	dir, data := t.TempDir(), t.TempDir()
+	reopen = func() *Repo {
+		t.Helper()
+		f, err := wtfs.New(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := Open(dir, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	return reopen(), reopen
+}
+
+func body(t *testing.T, r *Repo, loc wikithing.Path) string {
+	t.Helper()
+	a, err := r.LoadPage(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(a.Pages[0].Body)
+}
+
+func stamp(t *testing.T, r *Repo, loc wikithing.Path) string {
+	t.Helper()
+	s, err := r.PageStamp(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSavePage(t *testing.T) {
+	r, _ := newRepo(t)
+	loc := wikithing.ParsePath("some/page")
+
+	err := r.SavePage(loc, article("first"), "", wikithing.LogEntry{Reason: "start"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := stamp(t, r, loc)
+	err = r.SavePage(loc, article("second"), base, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// saving over a revision that's gone gets a conflict and changes nothing
+	err = r.SavePage(loc, article("mine"), base, wikithing.LogEntry{})
+	if err != wtfs.ErrConflict {
+		t.Fatalf("saving over someone else's edit gave %v", err)
+	}
+	err = r.SavePage(loc, article("mine"), "", wikithing.LogEntry{})
+	if err != wtfs.ErrConflict {
+		t.Fatalf("creating a page that's there already gave %v", err)
+	}
+	if b := body(t, r, loc); b != "second" {
+		t.Errorf("the page is %q after conflicting saves", b)
+	}
+
+	revs, err := r.PageRevisions(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 {
+		t.Fatalf("there are %v revisions, not 2", len(revs))
+	}
+	first := revs[0]
+	if first.Reason != "start" || first.Action != wikithing.LogActionCreate {
+		t.Errorf("the first revision is %+v", first)
+	}
+
+	err = r.RevertPage(loc, first.When, wikithing.LogEntry{Reason: "back"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := body(t, r, loc); b != "first" {
+		t.Errorf("reverting gave %q", b)
+	}
+	l, err := r.PageLog(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := l.Entries[len(l.Entries)-1]
+	if last.Action != wikithing.LogActionRevert || last.Ref != first.Stamp {
+		t.Errorf("the revert was logged as %+v", last)
+	}
+
+	if _, err := r.LoadPage(wikithing.ParsePath("not/here")); !os.IsNotExist(err) {
+		t.Errorf("loading a page that isn't there gave %v", err)
+	}
+}
+
+// the log of every page is worked out again from the commits when the repository is opened
+func TestReopen(t *testing.T) {
+	r, reopen := newRepo(t)
+	a, b, c := wikithing.ParsePath("a"), wikithing.ParsePath("b"), wikithing.ParsePath("c")
+
+	for _, x := range []wikithing.Path{a, c} {
+		err := r.SavePage(x, article(x.String()), "", wikithing.LogEntry{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := r.SavePage(a, article("again"), stamp(t, r, a), wikithing.LogEntry{Reason: "more"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.MovePage(a, b, wikithing.LogEntry{Reason: "moving"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.DeletePage(c, wikithing.LogEntry{Reason: "gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again := reopen()
+	for _, x := range []wikithing.Path{a, b, c} {
+		want, err := r.PageLog(x)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		got, err := again.PageLog(x)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("the log of %v came back as\n%+v\nnot\n%+v", x, got, want)
+		}
+	}
+	if l, _ := again.PageLog(b); len(l.Entries) != 3 || l.Entries[0].Reason != "" {
+		t.Errorf("the moved page's log came back as %+v", l)
+	}
+
+	want, err := r.DeletedPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := again.DeletedPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) || len(got) != 1 {
+		t.Errorf("the trash came back as %+v not %+v", got, want)
+	}
+
+	if s := body(t, again, b); s != "again" {
+		t.Errorf("the moved page is %q", s)
+	}
+	revs, err := again.PageRevisions(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 {
+		t.Fatalf("the moved page has %v revisions, not 2", len(revs))
+	}
+	old, err := again.LoadPageRevision(b, revs[0].When)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.TrimSpace(old.Pages[0].Body); s != "a" {
+		t.Errorf("the first revision of the moved page is %q", s)
+	}
+}
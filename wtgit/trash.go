@@ -0,0 +1,90 @@
+package wtgit
+
+import (
+	"os"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+// DeletePage removes a page in a new commit, its history is kept in the trash so it can be restored,
+// its discussion goes in the data directory's trash under the same stamp
+func (r *Repo) DeletePage(loc wikithing.Path, why wikithing.LogEntry) error {
+	at := time.Now().UTC()
+	err := func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		ok, err := r.exists(file(loc))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return os.ErrNotExist
+		}
+
+		why.When = at
+		why.Action = wikithing.LogActionDelete
+		return r.commit(loc, why, map[string][]byte{file(loc): nil})
+	}()
+	if err != nil {
+		return err
+	}
+	r.PageRemoved(loc)
+
+	return r.DeleteTalk(loc, why, at)
+}
+
+// RestorePage brings a page back out of the trash as it was when it was deleted,
+// failing with os.ErrExist if something else is at its path now
+func (r *Repo) RestorePage(loc wikithing.Path, stamp string, why wikithing.LogEntry) error {
+	err := wtfs.CheckStamp(stamp)
+	if err != nil {
+		return err
+	}
+
+	var a wikithing.Article
+	err = func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		l, ok := r.trash[trashKey(loc, stamp)]
+		if !ok {
+			return os.ErrNotExist
+		}
+		if _, ok := r.logs[loc.String()]; ok {
+			return os.ErrExist
+		}
+		e, ok := lastContent(l)
+		if !ok {
+			return os.ErrNotExist
+		}
+		err := r.read(e.Commit, e.File, &a)
+		if err != nil {
+			return err
+		}
+		b, err := encode(a)
+		if err != nil {
+			return err
+		}
+
+		why.When = time.Now().UTC()
+		why.Action = wikithing.LogActionRestore
+		why.Ref = stamp
+		return r.commit(loc, why, map[string][]byte{file(loc): b})
+	}()
+	if err != nil {
+		return err
+	}
+	r.PageChanged(loc, a)
+
+	return r.RestoreTalk(loc, stamp, why)
+}
+
+// DeletedPages lists every page in the trash, most recently deleted first
+func (r *Repo) DeletedPages() ([]wtfs.Deleted, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.listTrash(), nil
+}
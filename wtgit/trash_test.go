@@ -0,0 +1,73 @@
+package wtgit
+
+import (
+	"os"
+	"testing"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+)
+
+func TestDeleteRestore(t *testing.T) {
+	r, _ := newRepo(t)
+	loc := wikithing.ParsePath("gone")
+
+	err := r.SavePage(loc, article("first"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.SavePage(loc, article("second"), stamp(t, r, loc), wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.DeletePage(loc, wikithing.LogEntry{Reason: "not wanted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.LoadPage(loc); !os.IsNotExist(err) {
+		t.Fatalf("the page is still there after deleting it (%v)", err)
+	}
+	if err := r.DeletePage(loc, wikithing.LogEntry{}); !os.IsNotExist(err) {
+		t.Errorf("deleting it again gave %v", err)
+	}
+
+	l, err := r.DeletedPages()
+	if err != nil || len(l) != 1 {
+		t.Fatalf("got %v %v from the trash", l, err)
+	}
+	if l[0].Entry.Reason != "not wanted" {
+		t.Errorf("the trash has %+v", l[0])
+	}
+
+	if err := r.RestorePage(loc, "../../pages", wikithing.LogEntry{}); err != wtfs.ErrBadStamp {
+		t.Errorf("restoring with a bad stamp gave %v", err)
+	}
+
+	// a new page in the way stops it coming back
+	err = r.SavePage(loc, article("new"), "", wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RestorePage(loc, l[0].Stamp, wikithing.LogEntry{}); !os.IsExist(err) {
+		t.Errorf("restoring over another page gave %v", err)
+	}
+	err = r.DeletePage(loc, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.RestorePage(loc, l[0].Stamp, wikithing.LogEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := body(t, r, loc); b != "second" {
+		t.Errorf("the page came back as %q", b)
+	}
+	revs, err := r.PageRevisions(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 {
+		t.Errorf("the history came back with %v revisions, not 2", len(revs))
+	}
+}
@@ -0,0 +1,120 @@
+package wtgit
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wtfs"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ListChildren lists everything directly under a page, sorted by name,
+// the zero path lists everything at the top
+func (r *Repo) ListChildren(loc wikithing.Path) ([]wtfs.Child, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, err := r.tree(loc.Path())
+	if err != nil || t == nil {
+		return nil, err
+	}
+
+	found := map[string]*wtfs.Child{}
+	var out []*wtfs.Child
+	for _, x := range t.Entries {
+		name := x.Name
+		if x.Mode != filemode.Dir {
+			if !strings.HasSuffix(name, wtfs.Extension) {
+				continue
+			}
+			name = strings.TrimSuffix(name, wtfs.Extension)
+		}
+
+		c, ok := found[name]
+		if !ok {
+			c = &wtfs.Child{Path: loc.Child(name)}
+			// skip anything that wouldn't survive being turned into a path and back
+			if c.Path.Base() != name {
+				continue
+			}
+			found[name] = c
+			out = append(out, c)
+		}
+		if x.Mode == filemode.Dir {
+			c.HasChildren = true
+		} else {
+			c.Exists = true
+		}
+	}
+
+	l := make([]wtfs.Child, len(out))
+	for i, x := range out {
+		l[i] = *x
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Path.Base() < l[j].Path.Base() })
+	return l, nil
+}
+
+type page struct {
+	loc wikithing.Path
+	a   wikithing.Article
+}
+
+// allPages loads every page in the latest commit, parents before their children
+func (r *Repo) allPages() ([]page, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, err := r.tree("")
+	if err != nil || t == nil {
+		return nil, err
+	}
+
+	var l []page
+	return l, r.walkTree(t, "", &l)
+}
+
+func (r *Repo) walkTree(t *object.Tree, dir string, l *[]page) error {
+	for i, x := range t.Entries {
+		if x.Mode == filemode.Dir || !strings.HasSuffix(x.Name, wtfs.Extension) {
+			continue
+		}
+		name := path.Join(dir, x.Name)
+		p := wikithing.ParsePath(strings.TrimSuffix(name, wtfs.Extension))
+		if file(p) != name {
+			continue
+		}
+
+		f, err := t.TreeEntryFile(&t.Entries[i])
+		if err != nil {
+			return err
+		}
+		s, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		pg := page{loc: p}
+		if err := json.Unmarshal([]byte(s), &pg.a); err != nil {
+			return err
+		}
+		*l = append(*l, pg)
+	}
+
+	for _, x := range t.Entries {
+		if x.Mode != filemode.Dir {
+			continue
+		}
+		st, err := r.Git.TreeObject(x.Hash)
+		if err != nil {
+			return err
+		}
+		if err := r.walkTree(st, path.Join(dir, x.Name), l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Package wtgit keeps the wiki's pages in a git repository, every change to a page is a commit.
+//
+// The repository is bare, and each page is a json file at its path (some.page is some/page.json) so it can be cloned,
+// grepped and reviewed with normal git tools. Who made a change and why is the author and message of the commit,
+// and trailers at the end of the message hold the rest of the log entry so the history of every page can be worked out again from git alone.
+//
+// Only pages are kept in git, users, permissions, media and discussions stay in a wtfs.Filesystem,
+// not least because the repository is meant to be handed out and users have password hashes
+package wtgit
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/wtfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Repo is a wiki with its pages in a git repository and everything else in a wtfs.Filesystem
+type Repo struct {
+	// the page methods of Filesystem are all replaced, the rest are used as they are
+	*wtfs.Filesystem
+
+	Git *git.Repository
+
+	// mu is held for everything, go-git isn't safe to use from multiple goroutines
+	mu sync.Mutex
+	// logs has the log of every path that has ever had a page at it, by the path's string
+	logs map[string][]entry
+	// trash has the logs of deleted pages, by path@stamp the same as in wtfs
+	trash map[string][]entry
+}
+
+var _ wtfs.Store = (*Repo)(nil)
+
+// entry is a log entry along with the commit that made it and where the page's file was at the time,
+// which isn't always where it is now since pages carry their history with them when they're moved
+type entry struct {
+	wikithing.LogEntry
+
+	Commit plumbing.Hash
+	File   string
+}
+
+// Open opens the repository in dir, making a new bare one if there isn't one there yet
+func Open(dir string, data *wtfs.Filesystem) (*Repo, error) {
+	g, err := git.PlainOpen(dir)
+	if err == git.ErrRepositoryNotExists {
+		g, err = git.PlainInit(dir, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Repo{
+		Filesystem: data,
+		Git:        g,
+		logs:       map[string][]entry{},
+		trash:      map[string][]entry{},
+	}
+	return r, r.replay()
+}
+
+// file is where a page is kept in the repository
+func file(p wikithing.Path) string { return p.Path() + wtfs.Extension }
+
+func trashKey(p wikithing.Path, stamp string) string { return p.String() + "@" + stamp }
+
+// Trailers on the end of commit messages
+const (
+	trailerPage   = "Wiki-Page: "
+	trailerAction = "Wiki-Action: "
+	trailerActor  = "Wiki-Actor: "
+	trailerWhen   = "Wiki-When: "
+	trailerRef    = "Wiki-Ref: "
+)
+
+// subject makes up a subject line for commits without a reason, parseMessage knows to leave it out of the log entry
+func subject(p wikithing.Path, action uint) string {
+	return wikithing.LogActionNames[action] + " " + p.String()
+}
+
+// message makes the commit message for a log entry
+func message(p wikithing.Path, le wikithing.LogEntry) string {
+	b := &strings.Builder{}
+	if le.Reason != "" {
+		b.WriteString(le.Reason)
+	} else {
+		b.WriteString(subject(p, le.Action))
+	}
+	b.WriteString("\n\n")
+	b.WriteString(trailerPage + p.String() + "\n")
+	b.WriteString(trailerAction + wikithing.LogActionNames[le.Action] + "\n")
+	b.WriteString(trailerActor + le.Actor.String() + "\n")
+	b.WriteString(trailerWhen + le.When.Format(wtfs.TimeFormat) + "\n")
+	if le.Ref != "" {
+		b.WriteString(trailerRef + le.Ref + "\n")
+	}
+	return b.String()
+}
+
+// parseMessage gets the page and log entry back out of a commit message,
+// commits that weren't made by the wiki don't have them and are skipped
+func parseMessage(msg string) (wikithing.Path, wikithing.LogEntry, bool) {
+	var (
+		p  wikithing.Path
+		le wikithing.LogEntry
+	)
+
+	i := strings.LastIndex(msg, "\n\n"+trailerPage)
+	if i < 0 {
+		return p, le, false
+	}
+	le.Reason = strings.TrimSpace(msg[:i])
+
+	for _, x := range strings.Split(msg[i+2:], "\n") {
+		switch {
+		case strings.HasPrefix(x, trailerPage):
+			p = wikithing.ParsePath(x[len(trailerPage):])
+		case strings.HasPrefix(x, trailerAction):
+			le.Action = actionByName(x[len(trailerAction):])
+		case strings.HasPrefix(x, trailerActor):
+			le.Actor, _ = sid.Parse(x[len(trailerActor):])
+		case strings.HasPrefix(x, trailerWhen):
+			le.When, _ = time.Parse(wtfs.TimeFormat, x[len(trailerWhen):])
+		case strings.HasPrefix(x, trailerRef):
+			le.Ref = x[len(trailerRef):]
+		}
+	}
+
+	if le.Reason == subject(p, le.Action) {
+		le.Reason = ""
+	}
+	return p, le, !p.IsZero() && le.Action != wikithing.LogActionUnspecified && !le.When.IsZero()
+}
+
+func actionByName(s string) uint {
+	for k, v := range wikithing.LogActionNames {
+		if v == s {
+			return k
+		}
+	}
+	return wikithing.LogActionUnspecified
+}
+
+// replay works out the log of every page by going through every commit from the start
+func (r *Repo) replay() error {
+	head, err := r.Git.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var l []*object.Commit
+	it, err := r.Git.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return err
+	}
+	err = it.ForEach(func(c *object.Commit) error {
+		l = append(l, c)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(l) - 1; i >= 0; i-- {
+		p, le, ok := parseMessage(l[i].Message)
+		if !ok {
+			continue
+		}
+		r.record(p, entry{LogEntry: le, Commit: l[i].Hash, File: file(p)})
+	}
+	return nil
+}
+
+// record adds an entry to the logs, doing the same to them as the action did to the page
+func (r *Repo) record(p wikithing.Path, e entry) {
+	k := p.String()
+
+	switch e.Action {
+	case wikithing.LogActionMove:
+		from, to := wikithing.ParseMoveRef(e.Ref)
+		r.logs[to.String()] = append(r.logs[from.String()], e)
+		r.logs[from.String()] = []entry{e}
+	case wikithing.LogActionDelete:
+		r.trash[trashKey(p, e.When.Format(wtfs.TimeFormat))] = append(r.logs[k], e)
+		delete(r.logs, k)
+	case wikithing.LogActionRestore:
+		t := trashKey(p, e.Ref)
+		r.logs[k] = append(r.trash[t], e)
+		delete(r.trash, t)
+	default:
+		r.logs[k] = append(r.logs[k], e)
+	}
+}
+
+// logFile turns entries into a log
+func logFile(l []entry) wikithing.LogFile {
+	var lf wikithing.LogFile
+	for _, x := range l {
+		lf.Entries = append(lf.Entries, x.LogEntry)
+	}
+	return lf
+}
+
+// lastContent finds the entry for the latest revision in a log
+func lastContent(l []entry) (entry, bool) {
+	for i := len(l) - 1; i >= 0; i-- {
+		if wtfs.ChangesContent(l[i].Action) {
+			return l[i], true
+		}
+	}
+	return entry{}, false
+}
+
+// listTrash lists the deleted pages, most recently deleted first
+func (r *Repo) listTrash() []wtfs.Deleted {
+	var out []wtfs.Deleted
+	for k, l := range r.trash {
+		i := strings.LastIndexByte(k, '@')
+		if i < 0 || len(l) == 0 {
+			continue
+		}
+		out = append(out, wtfs.Deleted{
+			Path:  wikithing.ParsePath(k[:i]),
+			Stamp: k[i+1:],
+			Entry: l[len(l)-1].LogEntry,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Stamp > out[j].Stamp })
+	return out
+}
@@ -0,0 +1,34 @@
+package wtgit
+
+import (
+	"testing"
+	"time"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+)
+
+func TestMessage(t *testing.T) {
+	p := wikithing.ParsePath("some/page")
+	when := time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.UTC)
+
+	for _, le := range []wikithing.LogEntry{
+		{Reason: "fixing the spelling", Action: wikithing.LogActionEdit, Actor: sid.Get(), When: when},
+		{Action: wikithing.LogActionEdit, When: when},
+		{Action: wikithing.LogActionMove, When: when, Ref: wikithing.MoveRef(p, wikithing.ParsePath("other/page"))},
+		// a reason that's just like the made up subject but for another page stays
+		{Reason: "Edit other/page", Action: wikithing.LogActionEdit, When: when},
+	} {
+		got, back, ok := parseMessage(message(p, le))
+		if !ok {
+			t.Errorf("%v didn't come back out of its message", le)
+			continue
+		}
+		if !got.Equal(p) {
+			t.Errorf("the page came back as %v", got)
+		}
+		if back != le {
+			t.Errorf("%+v came back as %+v", le, back)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package wtlinks keeps track of which pages link to which
+package wtlinks
+
+import (
+	"sort"
+	"sync"
+
+	"git.lan/wikithing"
+)
+
+// Graph is every link between pages, it's safe to use from multiple goroutines
+type Graph struct {
+	m sync.RWMutex
+
+	// paths has every path that has been seen, keyed by its string form
+	paths map[string]wikithing.Path
+	// exists is the pages that actually exist, pages that are only linked to aren't in it
+	exists map[string]bool
+
+	out map[string]map[string]bool
+	in  map[string]map[string]bool
+}
+
+func New() *Graph {
+	return &Graph{
+		paths:  make(map[string]wikithing.Path),
+		exists: make(map[string]bool),
+		out:    make(map[string]map[string]bool),
+		in:     make(map[string]map[string]bool),
+	}
+}
+
+// Set records a page as existing and replaces the links going out of it
+func (g *Graph) Set(from wikithing.Path, to []wikithing.Path) {
+	key := from.String()
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.clearOut(key)
+	g.paths[key] = from
+	g.exists[key] = true
+
+	out := make(map[string]bool, len(to))
+	for _, x := range to {
+		k := x.String()
+		if k == key {
+			// pages linking to themselves don't count
+			continue
+		}
+		g.paths[k] = x
+		out[k] = true
+
+		if g.in[k] == nil {
+			g.in[k] = make(map[string]bool)
+		}
+		g.in[k][key] = true
+	}
+	g.out[key] = out
+}
+
+// Remove records a page as not existing anymore, links to it are kept since they're now wanted
+func (g *Graph) Remove(p wikithing.Path) {
+	key := p.String()
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.clearOut(key)
+	delete(g.exists, key)
+	delete(g.out, key)
+	if len(g.in[key]) == 0 {
+		delete(g.paths, key)
+	}
+}
+
+func (g *Graph) clearOut(key string) {
+	for k := range g.out[key] {
+		delete(g.in[k], key)
+		if len(g.in[k]) == 0 {
+			delete(g.in, k)
+			if !g.exists[k] {
+				delete(g.paths, k)
+			}
+		}
+	}
+}
+
+// Exists checks if a page is known to exist
+func (g *Graph) Exists(p wikithing.Path) bool {
+	g.m.RLock()
+	defer g.m.RUnlock()
+	return g.exists[p.String()]
+}
+
+// Outbound lists the pages a page links to
+func (g *Graph) Outbound(p wikithing.Path) []wikithing.Path {
+	g.m.RLock()
+	defer g.m.RUnlock()
+	return g.list(g.out[p.String()])
+}
+
+// Inbound lists the pages that link to a page
+func (g *Graph) Inbound(p wikithing.Path) []wikithing.Path {
+	g.m.RLock()
+	defer g.m.RUnlock()
+	return g.list(g.in[p.String()])
+}
+
+// list turns a set of keys into paths, sorted so they come out the same every time
+func (g *Graph) list(m map[string]bool) []wikithing.Path {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]wikithing.Path, len(keys))
+	for i, k := range keys {
+		out[i] = g.paths[k]
+	}
+	return out
+}
+
+// Wanted is a page that's linked to but doesn't exist
+type Wanted struct {
+	Path wikithing.Path
+	From []wikithing.Path
+}
+
+// Wanted lists every page that is linked to but doesn't exist, the most linked to first
+func (g *Graph) Wanted() []Wanted {
+	g.m.RLock()
+	defer g.m.RUnlock()
+
+	var out []Wanted
+	for k, from := range g.in {
+		if g.exists[k] {
+			continue
+		}
+		out = append(out, Wanted{Path: g.paths[k], From: g.list(from)})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].From) != len(out[j].From) {
+			return len(out[i].From) > len(out[j].From)
+		}
+		return out[i].Path.String() < out[j].Path.String()
+	})
+	return out
+}
+
+// Orphans lists every page that exists but nothing links to
+func (g *Graph) Orphans() []wikithing.Path {
+	g.m.RLock()
+	defer g.m.RUnlock()
+
+	orphans := map[string]bool{}
+	for k := range g.exists {
+		if len(g.in[k]) == 0 {
+			orphans[k] = true
+		}
+	}
+	return g.list(orphans)
+}
@@ -0,0 +1,186 @@
+package wtquery
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"git.lan/wikithing"
+)
+
+// maxDefaultColumns is how many columns are shown when a query doesn't say which ones
+const maxDefaultColumns = 6
+
+// Index keeps the tables of every page for querying, it's safe to use from multiple goroutines
+type Index struct {
+	m     sync.RWMutex
+	pages map[string]Row
+}
+
+// Row is a page in the results of a query
+type Row struct {
+	Path  wikithing.Path
+	Title string
+	Table wikithing.Table
+}
+
+// Result is everything that matched a query
+type Result struct {
+	// Columns are the fields that should be shown
+	Columns []string
+	Rows    []Row
+	// More is set if there were more matches than the limit
+	More bool
+}
+
+func New() *Index {
+	return &Index{pages: make(map[string]Row)}
+}
+
+// Set adds or updates the table of a page, the tables of all pages in an article are combined with the first page winning
+func (ix *Index) Set(p wikithing.Path, a wikithing.Article) {
+	row := Row{Path: p, Title: p.String()}
+	for i, x := range a.Pages {
+		if i == 0 {
+			row.Table.Title = x.Table.Title
+			if x.Title != "" {
+				row.Title = x.Title
+			}
+		}
+		for _, f := range x.Table.Fields {
+			if _, ok := row.Table.Get(f.Key); !ok {
+				row.Table.Fields = append(row.Table.Fields, f)
+			}
+		}
+	}
+
+	ix.m.Lock()
+	defer ix.m.Unlock()
+	ix.pages[p.String()] = row
+}
+
+// Remove takes a page out of the index
+func (ix *Index) Remove(p wikithing.Path) {
+	ix.m.Lock()
+	defer ix.m.Unlock()
+	delete(ix.pages, p.String())
+}
+
+// Get gets the values of a key, including path and title, unless the table has fields with those names
+func (r Row) Get(key string) ([]string, bool) {
+	if v, ok := r.Table.Get(key); ok {
+		return v, true
+	}
+	switch strings.ToLower(key) {
+	case "path":
+		return []string{r.Path.String()}, true
+	case "title":
+		return []string{r.Title}, true
+	}
+	return nil, false
+}
+
+// Values gets the values of a key, or nothing if the row doesn't have it
+func (r Row) Values(key string) []string {
+	v, _ := r.Get(key)
+	return v
+}
+
+// Run runs a query, if allow isn't nil then only the pages it allows are included
+func (ix *Index) Run(q Query, allow func(wikithing.Path) bool) Result {
+	ix.m.RLock()
+	var rows []Row
+	for _, x := range ix.pages {
+		if q.match(x) {
+			rows = append(rows, x)
+		}
+	}
+	ix.m.RUnlock()
+
+	sortRows(rows, q.Sort, q.Desc)
+
+	var res Result
+	for _, x := range rows {
+		if allow != nil && !allow(x.Path) {
+			continue
+		}
+		if len(res.Rows) == q.Limit {
+			res.More = true
+			break
+		}
+		res.Rows = append(res.Rows, x)
+	}
+	// worked out after filtering so the names of fields on hidden pages don't show up
+	res.Columns = q.columns(res.Rows)
+	return res
+}
+
+func (q Query) match(r Row) bool {
+	if q.Pattern != "" && !wikithing.MatchPattern(q.Pattern, r.Path) {
+		return false
+	}
+	for _, c := range q.Conds {
+		if !c.match(r.Get(c.Key)) {
+			return false
+		}
+	}
+	return true
+}
+
+// columns works out which fields to show, either the ones the query asks for, the ones it checks,
+// or if it doesn't say anything about fields then the first few in the results
+func (q Query) columns(rows []Row) []string {
+	if len(q.Show) > 0 {
+		return q.Show
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	add := func(k string) {
+		lk := strings.ToLower(k)
+		if seen[lk] || lk == "" || lk == "path" || lk == "title" {
+			return
+		}
+		seen[lk] = true
+		out = append(out, k)
+	}
+
+	for _, c := range q.Conds {
+		add(c.Key)
+	}
+	if len(out) > 0 {
+		return out
+	}
+
+	for _, r := range rows {
+		for _, f := range r.Table.Fields {
+			if len(out) == maxDefaultColumns {
+				return out
+			}
+			add(f.Key)
+		}
+	}
+	return out
+}
+
+func sortRows(rows []Row, key string, desc bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if key != "" {
+			a, aok := rows[i].Get(key)
+			b, bok := rows[j].Get(key)
+			aok, bok = aok && len(a) > 0, bok && len(b) > 0
+			// pages without the field always go last
+			switch {
+			case aok && !bok:
+				return true
+			case !aok && bok:
+				return false
+			case aok && bok:
+				if c := compare(a[0], b[0]); c != 0 {
+					return (c < 0) != desc
+				}
+			}
+		}
+		return rows[i].Path.String() < rows[j].Path.String()
+	})
+}
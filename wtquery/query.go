@@ -0,0 +1,253 @@
+// Package wtquery finds pages by what's in their tables
+//
+// A query is a list of clauses separated by spaces, all of which have to match:
+//
+//	services.*          only pages under services (or just services for that one page)
+//	owner=platform      a value of the owner field is platform, ignoring case
+//	owner!=platform     no value of owner is platform
+//	owner~=plat         a value of owner contains plat
+//	owner=*             the page has an owner field at all
+//	tier<3  tier>1      a value of tier is less or more than something, as numbers if they both are
+//	sort:owner          sort by the first value of owner, sort:-owner goes backwards
+//	show:owner,tier     the fields to show in the results
+//	limit:20            only give back this many results
+//
+// Values with spaces in them can be quoted like owner="platform team".
+// Besides the fields in a table, path and title can be used as keys too
+package wtquery
+
+import (
+	"strconv"
+	"strings"
+
+	"git.lan/wikithing/wterr"
+)
+
+// Limits on results
+const (
+	DefaultLimit = 100
+	MaxLimit     = 500
+)
+
+// Op is how a condition compares a field
+type Op uint
+
+// Ops
+const (
+	OpEq Op = iota
+	OpNe
+	OpContains
+	OpHas
+	OpLt
+	OpGt
+)
+
+// ops is the operators in the order they need to be looked for, so != isn't taken as =
+var ops = []struct {
+	s  string
+	op Op
+}{
+	{"!=", OpNe},
+	{"~=", OpContains},
+	{"=", OpEq},
+	{"<", OpLt},
+	{">", OpGt},
+}
+
+// Cond is a single condition a page has to match
+type Cond struct {
+	Key   string
+	Op    Op
+	Value string
+}
+
+// Query is a parsed query
+type Query struct {
+	// Pattern limits which pages are looked at, it's empty to look at every page
+	Pattern string
+	Conds   []Cond
+
+	Sort string
+	Desc bool
+	Show []string
+	// Limit is always set, to DefaultLimit if the query didn't give one
+	Limit int
+}
+
+// Parse parses a query, see the package documentation for what it looks like
+func Parse(s string) (Query, error) {
+	q := Query{Limit: DefaultLimit}
+
+	toks, err := split(s)
+	if err != nil {
+		return q, err
+	}
+	if len(toks) == 0 {
+		return q, wterr.New(wterr.ErrInvalidInput, "empty query")
+	}
+
+	for _, t := range toks {
+		if opt, ok := option(t); ok {
+			err := q.setOption(opt, t[len(opt)+1:])
+			if err != nil {
+				return q, err
+			}
+			continue
+		}
+
+		if c, ok := cond(t); ok {
+			if c.Key == "" {
+				return q, wterr.Newf(wterr.ErrInvalidInput, "missing a key in `%v`", t)
+			}
+			q.Conds = append(q.Conds, c)
+			continue
+		}
+
+		if q.Pattern != "" {
+			return q, wterr.Newf(wterr.ErrInvalidInput, "only one path can be given, got `%v` and `%v`", q.Pattern, t)
+		}
+		q.Pattern = t
+	}
+
+	return q, nil
+}
+
+func option(t string) (string, bool) {
+	for _, x := range []string{"sort", "show", "limit"} {
+		if strings.HasPrefix(t, x+":") {
+			return x, true
+		}
+	}
+	return "", false
+}
+
+func (q *Query) setOption(opt, v string) error {
+	switch opt {
+	case "sort":
+		q.Desc = strings.HasPrefix(v, "-")
+		q.Sort = strings.ToLower(strings.TrimPrefix(v, "-"))
+		if q.Sort == "" {
+			return wterr.New(wterr.ErrInvalidInput, "sort needs a key to sort by")
+		}
+	case "show":
+		for _, x := range strings.Split(v, ",") {
+			if x = strings.TrimSpace(x); x != "" {
+				q.Show = append(q.Show, x)
+			}
+		}
+	case "limit":
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return wterr.Newf(wterr.ErrInvalidInput, "invalid limit `%v`", v)
+		}
+		if n > MaxLimit {
+			n = MaxLimit
+		}
+		q.Limit = n
+	}
+	return nil
+}
+
+func cond(t string) (Cond, bool) {
+	for _, x := range ops {
+		i := strings.Index(t, x.s)
+		if i < 0 {
+			continue
+		}
+		c := Cond{
+			Key:   strings.TrimSpace(t[:i]),
+			Op:    x.op,
+			Value: t[i+len(x.s):],
+		}
+		if c.Op == OpEq && c.Value == "*" {
+			c.Op, c.Value = OpHas, ""
+		}
+		return c, true
+	}
+	return Cond{}, false
+}
+
+// split splits a query up on spaces, keeping anything in quotes together and taking the quotes out
+func split(s string) ([]string, error) {
+	var out []string
+	buf := strings.Builder{}
+	quoted, started := false, false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n' || r == '\r'):
+			if started {
+				out = append(out, buf.String())
+				buf.Reset()
+				started = false
+			}
+		default:
+			buf.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, wterr.New(wterr.ErrInvalidInput, "unclosed quote")
+	}
+	if started {
+		out = append(out, buf.String())
+	}
+
+	return out, nil
+}
+
+// match checks if a list of values matches a condition
+func (c Cond) match(vals []string, ok bool) bool {
+	switch c.Op {
+	case OpHas:
+		return ok
+	case OpNe:
+		for _, v := range vals {
+			if strings.EqualFold(v, c.Value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, v := range vals {
+		switch c.Op {
+		case OpEq:
+			if strings.EqualFold(v, c.Value) {
+				return true
+			}
+		case OpContains:
+			if strings.Contains(strings.ToLower(v), strings.ToLower(c.Value)) {
+				return true
+			}
+		case OpLt:
+			if compare(v, c.Value) < 0 {
+				return true
+			}
+		case OpGt:
+			if compare(v, c.Value) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compare compares two values as numbers if they both are, otherwise as text ignoring case
+func compare(a, b string) int {
+	x, errx := strconv.ParseFloat(a, 64)
+	y, erry := strconv.ParseFloat(b, 64)
+	if errx == nil && erry == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
@@ -0,0 +1,86 @@
+package wtrender
+
+import (
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/formatthing"
+	fast "git.lan/wikithing/formatthing/ast"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Links finds every page a page links to, each one is only listed once
+func Links(p wikithing.Page) []wikithing.Path {
+	var l linkList
+
+	switch p.Format {
+	case wikithing.FormatMarkdown:
+		src := []byte(p.Body)
+		doc := md.Parser().Parse(text.NewReader(src))
+		ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if x, ok := n.(*ast.Link); ok && entering {
+				// both [[wiki links]] and regular links to pages count
+				if t, ok := pageURL(string(x.Destination)); ok {
+					l.add(t)
+				}
+			}
+			return ast.WalkContinue, nil
+		})
+
+	case wikithing.FormatFormatthing:
+		fast.Walk(formatthing.Parse(p.Body), func(n fast.Node) bool {
+			if x, ok := n.(*fast.WikiLink); ok {
+				l.add(x.Target)
+			}
+			return true
+		})
+	}
+
+	return l.l
+}
+
+// ArticleLinks finds every page any of the pages in an article link to, including where it redirects
+func ArticleLinks(a wikithing.Article) []wikithing.Path {
+	var l linkList
+	if t, ok := a.RedirectTarget(); ok {
+		l.add(t)
+	}
+	for _, x := range a.Pages {
+		for _, t := range Links(x) {
+			l.add(t)
+		}
+	}
+	return l.l
+}
+
+type linkList struct {
+	l    []wikithing.Path
+	seen map[string]bool
+}
+
+func (l *linkList) add(p wikithing.Path) {
+	if p.IsZero() {
+		return
+	}
+	if l.seen == nil {
+		l.seen = map[string]bool{}
+	}
+	if l.seen[p.String()] {
+		return
+	}
+	l.seen[p.String()] = true
+	l.l = append(l.l, p)
+}
+
+// pageURL gets the page a url on the site points to
+func pageURL(u string) (wikithing.Path, bool) {
+	if !strings.HasPrefix(u, "/page/") {
+		return wikithing.Path{}, false
+	}
+	u = strings.TrimPrefix(u, "/page/")
+	if i := strings.IndexAny(u, "#?"); i >= 0 {
+		u = u[:i]
+	}
+	return wikithing.ParsePath(u), true
+}
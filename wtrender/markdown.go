@@ -0,0 +1,155 @@
+package wtrender
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"git.lan/wikithing"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		extension.Footnote,
+		wikiLinks{},
+		queryBlocks{},
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+	),
+)
+
+// Markdown renders a markdown document into sanitised html with the default renderer
+func Markdown(s string) (template.HTML, error) {
+	return Default.Markdown(s)
+}
+
+// Markdown renders a markdown document into sanitised html
+func (r *Renderer) Markdown(s string) (template.HTML, error) {
+	pc := parser.NewContext()
+	pc.Set(queryKey, r.queryHTML)
+
+	buf := &bytes.Buffer{}
+	err := md.Convert([]byte(s), buf, parser.WithContext(pc))
+	if err != nil {
+		return "", err
+	}
+
+	return Sanitise(buf.Bytes()), nil
+}
+
+// wikiLinks is a goldmark extension adding [[wiki.links]]
+type wikiLinks struct{}
+
+func (wikiLinks) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(
+		// has to go before the regular link parser, which also triggers on [
+		util.Prioritized(wikiLinkParser{}, 150),
+	))
+}
+
+type wikiLinkParser struct{}
+
+func (wikiLinkParser) Trigger() []byte { return []byte{'['} }
+
+func (wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if len(line) < 4 || line[1] != '[' {
+		return nil
+	}
+
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	l, ok := wikithing.ParseLink(string(line[2 : end+2]))
+	if !ok {
+		return nil
+	}
+	block.Advance(end + 4)
+
+	link := ast.NewLink()
+	link.Destination = []byte(l.URL())
+	link.SetAttributeString("class", []byte("wikilink"))
+	link.AppendChild(link, ast.NewString([]byte(l.Label)))
+
+	return link
+}
+
+// queryKey is where the function used to run embedded queries is kept in the parser context
+var queryKey = parser.NewContextKey()
+
+// queryBlocks is a goldmark extension that runs fenced code blocks with the language query
+// as queries and shows the results in their place
+type queryBlocks struct{}
+
+func (queryBlocks) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(queryTransformer{}, 100),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(queryRenderer{}, 100),
+	))
+}
+
+// queryNode is a query that has already been run and rendered
+type queryNode struct {
+	ast.BaseBlock
+	HTML []byte
+}
+
+var kindQuery = ast.NewNodeKind("Query")
+
+func (n *queryNode) Kind() ast.NodeKind { return kindQuery }
+
+func (n *queryNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type queryTransformer struct{}
+
+func (queryTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	run, ok := pc.Get(queryKey).(func(string) []byte)
+	if !ok {
+		return
+	}
+	src := reader.Source()
+
+	// collected first since replacing nodes while walking confuses the walk
+	var blocks []*ast.FencedCodeBlock
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if cb, ok := n.(*ast.FencedCodeBlock); ok && entering && string(cb.Language(src)) == "query" {
+			blocks = append(blocks, cb)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, cb := range blocks {
+		q := &strings.Builder{}
+		for i := 0; i < cb.Lines().Len(); i++ {
+			l := cb.Lines().At(i)
+			q.WriteString(strings.TrimSpace(string(l.Value(src))))
+			q.WriteByte(' ')
+		}
+		cb.Parent().ReplaceChild(cb.Parent(), cb, &queryNode{HTML: run(strings.TrimSpace(q.String()))})
+	}
+}
+
+type queryRenderer struct{}
+
+func (queryRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindQuery, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			w.Write(n.(*queryNode).HTML)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
@@ -0,0 +1,59 @@
+package wtrender
+
+import (
+	"bytes"
+	"html/template"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/wterr"
+)
+
+// queryHTML runs a query embedded in a page and renders the results as a table,
+// anything that goes wrong is shown in place of the table rather than failing the whole page
+func (r *Renderer) queryHTML(q string) []byte {
+	buf := &bytes.Buffer{}
+	if r.Query == nil {
+		buf.WriteString(`<p class="query-error">queries can't be run here</p>` + "\n")
+		return buf.Bytes()
+	}
+
+	res, err := r.Query(q)
+	if err != nil {
+		msg := "the query couldn't be run"
+		if we, ok := err.(wterr.Err); ok {
+			msg = we.Str
+		}
+		buf.WriteString(`<p class="query-error">` + template.HTMLEscapeString(msg) + "</p>\n")
+		return buf.Bytes()
+	}
+	if len(res.Rows) == 0 {
+		buf.WriteString(`<p class="query-empty">nothing matched the query</p>` + "\n")
+		return buf.Bytes()
+	}
+
+	buf.WriteString(`<table class="query"><thead><tr><th>Page</th>`)
+	for _, x := range res.Columns {
+		buf.WriteString("<th>" + template.HTMLEscapeString(x) + "</th>")
+	}
+	buf.WriteString("</tr></thead><tbody>\n")
+	for _, x := range res.Rows {
+		buf.WriteString(`<tr><td><a href="` + template.HTMLEscapeString(wikithing.Link{Target: x.Path}.URL()) + `">` + template.HTMLEscapeString(x.Title) + "</a></td>")
+		for _, c := range res.Columns {
+			buf.WriteString("<td>")
+			for i, y := range x.Values(c) {
+				if i > 0 {
+					buf.WriteString("<br>")
+				}
+				buf.WriteString(template.HTMLEscapeString(y))
+			}
+			buf.WriteString("</td>")
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</tbody></table>\n")
+	if res.More {
+		buf.WriteString(`<p class="query-more">there are more results than are shown here</p>` + "\n")
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,109 @@
+// Package wtrender turns page bodies into html that is safe to put on the site
+package wtrender
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+
+	"git.lan/wikithing"
+	"git.lan/wikithing/etc/sid"
+	"git.lan/wikithing/formatthing"
+	fast "git.lan/wikithing/formatthing/ast"
+	"git.lan/wikithing/wtquery"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Renderer holds everything needed to render pages that refer to things outside of themselves
+type Renderer struct {
+	// MediaURL is where media files are served from
+	MediaURL string
+	// Media looks up a media file by its ID
+	Media func(id sid.ID) (wikithing.FileObject, error)
+	// Query runs a query embedded in a page, if it's nil then embedded queries aren't run
+	Query func(q string) (wtquery.Result, error)
+}
+
+// Default is used by Render, it has nowhere to get media from
+var Default = &Renderer{}
+
+// Render renders a page with the default renderer
+func Render(p wikithing.Page) (template.HTML, error) {
+	return Default.Render(p)
+}
+
+// Render turns a page body into html according to its format,
+// anything in a format that isn't recognised is shown as plain text
+func (r *Renderer) Render(p wikithing.Page) (template.HTML, error) {
+	switch p.Format {
+	case wikithing.FormatMarkdown:
+		return r.Markdown(p.Body)
+	case wikithing.FormatFormatthing:
+		return Sanitise(formatthing.Render(p.Body, formatthing.Options{
+			Table:    p.Table,
+			MediaURL: r.MediaURL,
+			Media:    r.Media,
+			Query:    r.queryHTML,
+		})), nil
+	default:
+		return Plain(p.Body), nil
+	}
+}
+
+// InlineInfobox checks if a page puts its table somewhere in its body itself,
+// otherwise it's up to whatever is showing the page to put it somewhere
+func InlineInfobox(p wikithing.Page) bool {
+	if p.Format != wikithing.FormatFormatthing {
+		return false
+	}
+
+	found := false
+	fast.Walk(formatthing.Parse(p.Body), func(n fast.Node) bool {
+		if _, ok := n.(*fast.Infobox); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// Plain escapes text and splits it into paragraphs on blank lines
+func Plain(s string) template.HTML {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	buf := strings.Builder{}
+	for _, x := range strings.Split(s, "\n\n") {
+		x = strings.TrimSpace(x)
+		if x == "" {
+			continue
+		}
+		buf.WriteString("<p>")
+		buf.WriteString(strings.ReplaceAll(template.HTMLEscapeString(x), "\n", "<br>"))
+		buf.WriteString("</p>\n")
+	}
+
+	return template.HTML(buf.String())
+}
+
+// policy is what everything gets run through before it's allowed out
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(false)
+	p.RequireNoFollowOnFullyQualifiedLinks(true)
+
+	// for footnotes and code highlighting
+	p.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).Globally()
+	p.AllowAttrs("role").Matching(bluemonday.SpaceSeparatedTokens).Globally()
+	p.AllowElements("section")
+
+	// for embedded media
+	p.AllowAttrs("src").OnElements("audio", "video")
+	p.AllowAttrs("controls").Matching(regexp.MustCompile(`^$`)).OnElements("audio", "video")
+
+	return p
+}()
+
+// Sanitise strips anything potentially unsafe out of some html
+func Sanitise(b []byte) template.HTML {
+	return template.HTML(policy.SanitizeBytes(b))
+}
@@ -0,0 +1,256 @@
+// Package wtsearch is a full text search index for wiki pages
+package wtsearch
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"git.lan/wikithing"
+)
+
+// Index is an inverted index of words to the pages they're in, it's safe to use from multiple goroutines
+type Index struct {
+	m sync.RWMutex
+
+	docs map[string]*document
+	// postings has every position of every word in every page
+	postings map[string]map[string][]int
+}
+
+// document is a page in the index,
+// positions count through the title first and then the body, with a gap between so phrases can't cross over
+type document struct {
+	Path  wikithing.Path
+	Title string
+	Body  string
+
+	// titleLen is how many words are in the title, positions after it are in the body
+	titleLen int
+	body     []token
+}
+
+func (d *document) bodyPos(pos int) int { return pos - d.titleLen - 1 }
+
+func New() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string][]int),
+	}
+}
+
+// Len is how many pages are in the index
+func (ix *Index) Len() int {
+	ix.m.RLock()
+	defer ix.m.RUnlock()
+	return len(ix.docs)
+}
+
+// Add adds a page to the index, replacing whatever was there for it before
+func (ix *Index) Add(p wikithing.Path, a wikithing.Article) {
+	d := &document{Path: p}
+
+	bodies := make([]string, 0, len(a.Pages))
+	for i, x := range a.Pages {
+		if i == 0 {
+			d.Title = x.Title
+			bodies = append(bodies, x.Body)
+			continue
+		}
+		// titles of any later pages are part of the body
+		bodies = append(bodies, x.Title, x.Body)
+	}
+	if d.Title == "" {
+		d.Title = p.String()
+	}
+	d.Body = strings.Join(bodies, "\n\n")
+
+	title := tokenise(d.Title)
+	d.titleLen = len(title)
+	d.body = tokenise(d.Body)
+
+	key := p.String()
+
+	ix.m.Lock()
+	defer ix.m.Unlock()
+
+	ix.remove(key)
+	ix.docs[key] = d
+	for i, x := range title {
+		ix.post(x.Term, key, i)
+	}
+	for i, x := range d.body {
+		ix.post(x.Term, key, d.titleLen+1+i)
+	}
+}
+
+func (ix *Index) post(term, key string, pos int) {
+	l, ok := ix.postings[term]
+	if !ok {
+		l = make(map[string][]int)
+		ix.postings[term] = l
+	}
+	l[key] = append(l[key], pos)
+}
+
+// Remove takes a page out of the index
+func (ix *Index) Remove(p wikithing.Path) {
+	ix.m.Lock()
+	defer ix.m.Unlock()
+	ix.remove(p.String())
+}
+
+func (ix *Index) remove(key string) {
+	d, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	delete(ix.docs, key)
+
+	seen := map[string]bool{}
+	check := func(t string) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		delete(ix.postings[t], key)
+		if len(ix.postings[t]) == 0 {
+			delete(ix.postings, t)
+		}
+	}
+	for _, x := range terms(d.Title) {
+		check(x)
+	}
+	for _, x := range d.body {
+		check(x.Term)
+	}
+}
+
+// Result is a page that matched a search
+type Result struct {
+	Path  wikithing.Path
+	Title string
+	Score float64
+
+	// Snippet is a bit of the page around the first match
+	Snippet Snippet
+}
+
+// Search finds the pages matching a query, best matches first
+func (ix *Index) Search(q Query) []Result {
+	if q.IsZero() {
+		return nil
+	}
+
+	ix.m.RLock()
+	defer ix.m.RUnlock()
+
+	// every page that matches has to match all of the parts of the query
+	var matches map[string][][]int
+	var idf []float64
+	for i, part := range q.Parts {
+		found := ix.matchPart(part)
+		// parts that are in fewer pages are worth more
+		idf = append(idf, math.Log(1+float64(len(ix.docs))/float64(1+len(found))))
+
+		next := make(map[string][][]int, len(found))
+		for k, starts := range found {
+			if i > 0 && matches[k] == nil {
+				continue
+			}
+			next[k] = append(matches[k], starts)
+		}
+		matches = next
+		if len(matches) == 0 {
+			return nil
+		}
+	}
+
+	out := make([]Result, 0, len(matches))
+	for k, starts := range matches {
+		d := ix.docs[k]
+
+		score := 0.0
+		var hits []int
+		for i, l := range starts {
+			for _, x := range l {
+				if x < d.titleLen {
+					// matches in the title count for a lot more
+					score += 3 * idf[i]
+					continue
+				}
+				score += idf[i]
+				for j := range q.Parts[i].Terms {
+					hits = append(hits, d.bodyPos(x+j))
+				}
+			}
+		}
+		// so long pages don't win just by being long
+		score /= 1 + math.Log(1+float64(len(d.body)))
+
+		out = append(out, Result{
+			Path:    d.Path,
+			Title:   d.Title,
+			Score:   score,
+			Snippet: d.snippet(hits),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Path.String() < out[j].Path.String()
+	})
+	return out
+}
+
+// matchPart finds where a part of a query is in each page, giving the position of the first word of each match
+func (ix *Index) matchPart(p Part) map[string][]int {
+	// the positions of each word of the part in each page
+	pos := make([]map[string]map[int]bool, len(p.Terms))
+	for i, t := range p.Terms {
+		pos[i] = map[string]map[int]bool{}
+
+		add := func(term string) {
+			for k, l := range ix.postings[term] {
+				if pos[i][k] == nil {
+					pos[i][k] = map[int]bool{}
+				}
+				for _, x := range l {
+					pos[i][k][x] = true
+				}
+			}
+		}
+
+		if p.Prefix && i == len(p.Terms)-1 {
+			for term := range ix.postings {
+				if strings.HasPrefix(term, t) {
+					add(term)
+				}
+			}
+		} else {
+			add(t)
+		}
+	}
+
+	out := map[string][]int{}
+	for k, first := range pos[0] {
+	next:
+		for x := range first {
+			for i := 1; i < len(pos); i++ {
+				if !pos[i][k][x+i] {
+					continue next
+				}
+			}
+			out[k] = append(out[k], x)
+		}
+		sort.Ints(out[k])
+		if len(out[k]) == 0 {
+			delete(out, k)
+		}
+	}
+
+	return out
+}
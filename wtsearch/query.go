@@ -0,0 +1,77 @@
+package wtsearch
+
+import "strings"
+
+// Query is a parsed search query, a page has to match every part of it to show up
+type Query struct {
+	Parts []Part
+}
+
+// Part is a single word or a quoted phrase in a query
+type Part struct {
+	Terms []string
+
+	// Prefix is set when the last term only has to match the start of a word, written as foo*
+	Prefix bool
+}
+
+// IsZero checks if a query has nothing to search for
+func (q Query) IsZero() bool { return len(q.Parts) == 0 }
+
+// ParseQuery parses a search query, words are matched on their own, "quoted words" have to appear together in order,
+// and ending a word with * matches anything starting with it
+func ParseQuery(s string) Query {
+	var q Query
+
+	for s != "" {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			break
+		}
+
+		var raw string
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				// an unclosed quote goes to the end
+				raw, s = s[1:], ""
+			} else {
+				raw, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(s, " \t\r\n\"")
+			if end < 0 {
+				end = len(s)
+			}
+			raw, s = s[:end], s[end:]
+		}
+
+		p := Part{Terms: terms(raw)}
+		if len(p.Terms) == 0 {
+			continue
+		}
+		p.Prefix = strings.HasSuffix(strings.TrimSpace(raw), "*")
+		q.Parts = append(q.Parts, p)
+	}
+
+	return q
+}
+
+func (p Part) String() string {
+	s := strings.Join(p.Terms, " ")
+	if p.Prefix {
+		s += "*"
+	}
+	if len(p.Terms) > 1 {
+		s = `"` + s + `"`
+	}
+	return s
+}
+
+func (q Query) String() string {
+	l := make([]string, len(q.Parts))
+	for i, x := range q.Parts {
+		l[i] = x.String()
+	}
+	return strings.Join(l, " ")
+}
@@ -0,0 +1,73 @@
+package wtsearch
+
+import "sort"
+
+// snippetWords is about how many words are shown in a snippet
+const snippetWords = 30
+
+// Snippet is a short bit of a page with the matching words marked
+type Snippet struct {
+	Parts []SnippetPart
+
+	// Before and After are set if there's more of the page before or after the snippet
+	Before, After bool
+}
+
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+// snippet cuts out the part of the body with the most matches in it, hits are the positions of matched words in the body
+func (d *document) snippet(hits []int) Snippet {
+	if len(d.body) == 0 {
+		return Snippet{}
+	}
+
+	sort.Ints(hits)
+	match := map[int]bool{}
+	for _, x := range hits {
+		match[x] = true
+	}
+
+	// find the window of words with the most hits in it
+	start, best := 0, -1
+	for i, x := range hits {
+		n := sort.SearchInts(hits[i:], x+snippetWords)
+		if n > best {
+			start, best = x, n
+		}
+	}
+	// with some context before the first match
+	start -= snippetWords / 5
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetWords
+	if end > len(d.body) {
+		end = len(d.body)
+	}
+
+	sn := Snippet{
+		Before: start > 0,
+		After:  end < len(d.body),
+	}
+
+	at := d.body[start].Start
+	for i := start; i < end; i++ {
+		t := d.body[i]
+		if !match[i] {
+			continue
+		}
+		if t.Start > at {
+			sn.Parts = append(sn.Parts, SnippetPart{Text: d.Body[at:t.Start]})
+		}
+		sn.Parts = append(sn.Parts, SnippetPart{Text: d.Body[t.Start:t.End], Match: true})
+		at = t.End
+	}
+	if last := d.body[end-1].End; last > at {
+		sn.Parts = append(sn.Parts, SnippetPart{Text: d.Body[at:last]})
+	}
+
+	return sn
+}
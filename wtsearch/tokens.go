@@ -0,0 +1,46 @@
+package wtsearch
+
+import (
+	"strings"
+	"unicode"
+)
+
+// token is a single word from a piece of text
+type token struct {
+	Term string
+
+	// Start and End are where the word is in the original text
+	Start, End int
+}
+
+// tokenise splits text up into lowercased words, anything that isn't a letter or number is a separator
+func tokenise(s string) []token {
+	var out []token
+
+	start := -1
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsNumber(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			out = append(out, token{Term: strings.ToLower(s[start:i]), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, token{Term: strings.ToLower(s[start:]), Start: start, End: len(s)})
+	}
+
+	return out
+}
+
+// terms gets just the words from some text
+func terms(s string) []string {
+	t := tokenise(s)
+	out := make([]string, len(t))
+	for i, x := range t {
+		out[i] = x.Term
+	}
+	return out
+}